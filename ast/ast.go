@@ -0,0 +1,141 @@
+// Package ast exposes the syntax tree of parsed expressions so tools can inspect and transform them, for example to
+// list the variables and functions an expression refers to. The node types are the ones used to evaluate expressions,
+// their fields follow the grammar of the expression language.
+//
+// Tools can read and set the fields that hold the parts of an expression: the operands and operators of Expression,
+// LogicalOpValue, ComparisonOpTerm, ComparisonOpValue and UnaryOpValue, the Number, String, Bool, NilSet and node
+// fields of Value, and the fields of the other nodes. Everything else is for the use of the parser and evaluator and
+// is not supported, in particular the struct tags holding the grammar, the Pos and EndPos positions the parser sets and
+// the Object, Array and Regexp fields of Value, which only hold data while an expression is evaluated. A node is
+// described by exactly one of the fields of a Value, Rewrite and Walk rely on it.
+package ast
+
+import (
+	iast "github.com/murphybytes/analyze/internal/ast"
+)
+
+// Node is implemented by every node of an expression tree.
+type Node = iast.Node
+
+// Nodes of an expression tree, the root of a parsed expression is an Expression.
+type (
+	// Expression is a chain of comparisons joined by && and || with an optional cond ? a : b conditional.
+	Expression = iast.Expression
+	// LogicalOpValue is a && or || operator and its right operand.
+	LogicalOpValue = iast.LogicalOpValue
+	// ComparisonOpTerm is a chain of operands joined by comparison operators such as == and in.
+	ComparisonOpTerm = iast.ComparisonOpTerm
+	// ComparisonOpValue is a comparison operator and its right operand.
+	ComparisonOpValue = iast.ComparisonOpValue
+	// UnaryOpValue is a value with an optional ! operator and postfix accessors.
+	UnaryOpValue = iast.UnaryOpValue
+	// Value is a literal or holds one of the other kinds of operand.
+	Value = iast.Value
+	// Variable is a reference to context data or a let binding, stored without the leading $.
+	Variable = iast.Variable
+	// Function is a call to a builtin or user defined function.
+	Function = iast.Function
+	// Accessor is a postfix .field, ["key"] or [index].
+	Accessor = iast.Accessor
+	// RegularExpression is a /pattern/flags literal.
+	RegularExpression = iast.RegularExpression
+	// ArrayLiteral is an inline [a, b] array.
+	ArrayLiteral = iast.ArrayLiteral
+	// ObjectLiteral is an inline {"key": value} object.
+	ObjectLiteral = iast.ObjectLiteral
+	// ObjectField is a field of an object literal.
+	ObjectField = iast.ObjectField
+	// Case is a case when ... then ... else ... end expression.
+	Case = iast.Case
+	// CaseBranch is a when ... then ... branch of a case expression.
+	CaseBranch = iast.CaseBranch
+	// Let is a let $name = value in body expression.
+	Let = iast.Let
+	// Binding binds a value to a name in a let expression.
+	Binding = iast.Binding
+)
+
+// Types used by the fields of nodes.
+type (
+	Operator    = iast.Operator
+	Boolean     = iast.Boolean
+	NilFlag     = iast.NilFlag
+	BindingName = iast.BindingName
+)
+
+// Operators held by LogicalOpValue, ComparisonOpValue and UnaryOpValue nodes.
+const (
+	OpUnknown              = iast.OpUnknown
+	OpUnaryNot             = iast.OpUnaryNot
+	OpLessThan             = iast.OpLessThan
+	OpLessThanEqual        = iast.OpLessThanEqual
+	OpGreaterThan          = iast.OpGreaterThan
+	OpGreaterThanOrEqualTo = iast.OpGreaterThanOrEqualTo
+	OpAnd                  = iast.OpAnd
+	OpOr                   = iast.OpOr
+	OpEqualTo              = iast.OpEqualTo
+	OpNotEqualTo           = iast.OpNotEqualTo
+	OpIn                   = iast.OpIn
+	OpNotIn                = iast.OpNotIn
+	OpMatch                = iast.OpMatch
+	OpNotMatch             = iast.OpNotMatch
+)
+
+// Parse parses an expression returning the root of its syntax tree.
+func Parse(expression string) (*Expression, error) {
+	var root Expression
+	if err := iast.Parser().ParseString("", expression, &root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// A Visitor's Visit method is called for each node encountered by Walk. If the result w is not nil, Walk visits each
+// of the children of node with w followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node depth first. It starts by calling v.Visit(node), if the visitor returned is
+// not nil Walk is called recursively with it for each of the children of node, followed by a call of w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	for _, child := range node.Children() {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node depth first calling f for each node. If f returns true Inspect visits
+// the children of node, followed by a call of f(nil).
+//
+// For example to list the functions an expression calls
+//
+//	ast.Inspect(root, func(n ast.Node) bool {
+//		if fn, ok := n.(*ast.Function); ok {
+//			names = append(names, fn.Name)
+//		}
+//		return true
+//	})
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite traverses the tree rooted at node depth first, replacing each node with the result of calling fn once its
+// children have been rewritten, and returns the result of calling fn on node. fn must return a non nil node of the
+// same type as the one it is passed, which may be the node itself after modifying it, otherwise Rewrite stops and
+// returns an error. The tree is modified in place so it may have been partly rewritten when an error is returned.
+func Rewrite(node Node, fn func(Node) Node) (Node, error) {
+	return iast.Rewrite(node, fn)
+}
@@ -0,0 +1,122 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/murphybytes/analyze/context"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	root, err := Parse(`let $n = @len($pods) in $n > 0 && @first(@select($pods, "$ready")).name =~ /^web-/ && case $kind when "Pod" then true end`)
+	require.Nil(t, err)
+
+	var variables, functions []string
+	regexps := 0
+	Inspect(root, func(n Node) bool {
+		switch t := n.(type) {
+		case *Variable:
+			variables = append(variables, string(*t))
+		case *Function:
+			functions = append(functions, t.Name)
+		case *RegularExpression:
+			regexps++
+		}
+		return true
+	})
+	// the name bound by let is part of its binding rather than a variable node
+	require.Equal(t, []string{"pods", "n", "pods", "kind"}, variables)
+	require.Equal(t, []string{"@len", "@first", "@select"}, functions)
+	require.Equal(t, 1, regexps)
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	root, err := Parse(`@len($a) > 0 && $b`)
+	require.Nil(t, err)
+
+	var variables []string
+	Inspect(root, func(n Node) bool {
+		switch t := n.(type) {
+		case *Function:
+			return false
+		case *Variable:
+			variables = append(variables, string(*t))
+		}
+		return true
+	})
+	require.Equal(t, []string{"b"}, variables)
+}
+
+type depthCounter struct {
+	depth, max *int
+}
+
+func (d depthCounter) Visit(n Node) Visitor {
+	if n == nil {
+		*d.depth--
+		return nil
+	}
+	if *d.depth++; *d.depth > *d.max {
+		*d.max = *d.depth
+	}
+	return d
+}
+
+func TestWalk(t *testing.T) {
+	root, err := Parse(`1 < 2`)
+	require.Nil(t, err)
+
+	depth, max := 0, 0
+	Walk(depthCounter{depth: &depth, max: &max}, root)
+	// Expression, ComparisonOpTerm, ComparisonOpValue, UnaryOpValue and Value, literals are held by the value
+	require.Equal(t, 5, max)
+	require.Equal(t, 0, depth)
+}
+
+func TestRewrite(t *testing.T) {
+	root, err := Parse(`$old.name == "web" && @len($old.ports) == 2`)
+	require.Nil(t, err)
+
+	result, err := Rewrite(root, func(n Node) Node {
+		if v, ok := n.(*Variable); ok && strings.HasPrefix(string(*v), "old.") {
+			renamed := Variable("new" + string(*v)[3:])
+			return &renamed
+		}
+		return n
+	})
+	require.Nil(t, err)
+	require.Equal(t, root, result)
+
+	ctx, err := context.New(map[string]interface{}{
+		"new": map[string]interface{}{"name": "web", "ports": []interface{}{80, 443}},
+	})
+	require.Nil(t, err)
+	v, err := root.Eval(ctx)
+	require.Nil(t, err)
+	require.True(t, bool(*v.Bool))
+}
+
+func TestRewriteWrongType(t *testing.T) {
+	root, err := Parse(`$a && @f($b)`)
+	require.Nil(t, err)
+	_, err = Rewrite(root, func(n Node) Node {
+		if _, ok := n.(*Variable); ok {
+			return &Function{Name: "@f"}
+		}
+		return n
+	})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "rewriting *ast.Variable returned *ast.Function")
+
+	// nil nodes are rejected too, typed or not
+	for _, replacement := range []Node{nil, (*Function)(nil)} {
+		_, err = Rewrite(root, func(n Node) Node {
+			if _, ok := n.(*Function); ok {
+				return replacement
+			}
+			return n
+		})
+		require.NotNil(t, err)
+	}
+}
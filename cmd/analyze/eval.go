@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/murphybytes/analyze/context"
+	"github.com/murphybytes/analyze/expression"
+	"github.com/murphybytes/analyze/internal/document"
+)
+
+// exitFalse is the exit status when an expression evaluates to false.
+const exitFalse = 1
+
+const evalUsage = `usage: analyze eval [flags] expression [file ...]
+
+Evaluates expression against each JSON or YAML file, or each line of an NDJSON file, and prints the results. Input is
+read from stdin if no file is given or the file is -, stdin is read as JSON unless -format is set. The exit status is
+0 if every result is true or isn't a bool, 1 if a result is false and 2 if an error occurs.
+
+flags:
+`
+
+// vars collects the --var name=file flags.
+type vars []string
+
+func (v *vars) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *vars) Set(s string) error {
+	if !strings.Contains(s, "=") {
+		return fmt.Errorf("expected name=file got %q", s)
+	}
+	*v = append(*v, s)
+	return nil
+}
+
+// runEval evaluates an expression against the documents in the files named by args or read from stdin.
+func runEval(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "", "input format, json, yaml or ndjson, detected from the file extension by default")
+	coerce := flags.Bool("coerce", false, "compare strings holding numbers with numbers numerically")
+	var bindings vars
+	flags.Var(&bindings, "var", "bind the document in a file to a variable, name=file, can be repeated")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, evalUsage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+	fail := func(format string, v ...interface{}) int {
+		fmt.Fprintf(stderr, "analyze eval: "+format+"\n", v...)
+		return exitError
+	}
+
+	prepared, err := expression.Prepare(flags.Arg(0))
+	if err != nil {
+		return fail("%s", err)
+	}
+	opts := []context.Option{}
+	if *coerce {
+		opts = append(opts, context.CoerceNumericStrings())
+	}
+	for _, binding := range bindings {
+		name, path := splitBinding(binding)
+		// the format of bound files is always detected from their extension
+		records, err := readFile(path, "", stdin)
+		if err != nil {
+			return fail("%s", err)
+		}
+		// an NDJSON file is bound as an array of its records
+		docs := make([]interface{}, 0, len(records))
+		for _, rec := range records {
+			docs = append(docs, rec.doc)
+		}
+		var val interface{} = docs
+		if len(docs) == 1 && detectFormat(path, "") != "ndjson" {
+			val = docs[0]
+		}
+		opts = append(opts, context.Var(name, val))
+	}
+
+	// check functions exist before reading any input
+	ctx, err := context.New(nil, opts...)
+	if err != nil {
+		return fail("%s", err)
+	}
+	if err := prepared.Validate(ctx); err != nil {
+		return fail("%s", err)
+	}
+
+	files := flags.Args()[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	for _, path := range files {
+		records, err := readFile(path, *format, stdin)
+		if err != nil {
+			return fail("%s", err)
+		}
+		for _, rec := range records {
+			ctx, err := context.New(rec.doc, opts...)
+			if err != nil {
+				return fail("%s", err)
+			}
+			result, err := prepared.EvaluateValue(ctx)
+			if err != nil {
+				return fail("%s: %s", location(path, rec.line), err)
+			}
+			out, err := json.Marshal(result)
+			if err != nil {
+				return fail("%s: %s", location(path, rec.line), err)
+			}
+			fmt.Fprintln(stdout, string(out))
+			if result == false {
+				status = exitFalse
+			}
+		}
+	}
+	return status
+}
+
+func splitBinding(binding string) (name, path string) {
+	i := strings.Index(binding, "=")
+	return binding[:i], binding[i+1:]
+}
+
+// location names the document being evaluated in error messages, line is the line an NDJSON record is on and 0 for
+// other documents.
+func location(path string, line int) string {
+	if path == "-" {
+		path = "stdin"
+	}
+	if line > 0 {
+		return fmt.Sprintf("%s:%d", path, line)
+	}
+	return path
+}
+
+// record is a document read from a file along with the line it is on in an NDJSON file, line is 0 for JSON and YAML
+// files which hold a single document.
+type record struct {
+	doc  interface{}
+	line int
+}
+
+// detectFormat returns the format of a file, format if it is set otherwise the one implied by the file extension.
+func detectFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	}
+	return "json"
+}
+
+// readFile returns the documents in a file, a JSON or YAML file holds a single document and an NDJSON file one
+// document per line.
+func readFile(path, format string, stdin io.Reader) ([]record, error) {
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch format = detectFormat(path, format); format {
+	case "json":
+		doc, err := document.ParseJSON(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", location(path, 0), err)
+		}
+		return []record{{doc: doc}}, nil
+	case "yaml":
+		doc, err := document.ParseYAML(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", location(path, 0), err)
+		}
+		return []record{{doc: doc}}, nil
+	case "ndjson":
+		return readLines(path, b)
+	}
+	return nil, fmt.Errorf("unknown format %q, expected json, yaml or ndjson", format)
+}
+
+// readLines decodes each line of an NDJSON document recording the line it is on, blank lines are skipped.
+func readLines(path string, b []byte) ([]record, error) {
+	var records []record
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	scanner.Buffer(nil, len(b)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		doc, err := document.ParseJSON(scanner.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", location(path, line), err)
+		}
+		records = append(records, record{doc: doc, line: line})
+	}
+	return records, scanner.Err()
+}
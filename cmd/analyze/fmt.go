@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/murphybytes/analyze/format"
+)
+
+// runFmt formats the expression passed as an argument or read from stdin.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: analyze fmt [expression]")
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	var src string
+	switch flags.NArg() {
+	case 0:
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "analyze fmt: %s\n", err)
+			return exitError
+		}
+		src = string(b)
+	case 1:
+		src = flags.Arg(0)
+	default:
+		flags.Usage()
+		return exitError
+	}
+	formatted, err := format.Source(src)
+	if err != nil {
+		fmt.Fprintf(stderr, "analyze fmt: %s\n", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, formatted)
+	return 0
+}
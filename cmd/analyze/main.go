@@ -0,0 +1,56 @@
+// Command analyze works with analyze expressions from the command line.
+//
+// Usage:
+//
+//	analyze eval [-format json|yaml|ndjson] [-var name=file ...] [-coerce] expression [file ...]
+//	analyze fmt [expression]
+//
+// The eval command evaluates an expression against each JSON or YAML file, or each line of an NDJSON file, and prints
+// the results as JSON, one per line. Input is read from standard input if no file is given. The exit status is 0 if
+// every result is true or isn't a bool, 1 if a result is false and 2 if an error occurs, so it can be used as a check
+// in shell scripts:
+//
+//	kubectl get deployment web -o json | analyze eval '$spec.replicas >= 2' || echo "web is not redundant"
+//
+// The fmt command prints an expression in canonical form, the expression is read from standard input if it isn't
+// passed as an argument. Comments are kept.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// exitError is the exit status when a command fails.
+const exitError = 2
+
+const usage = `usage: analyze <command> [arguments]
+
+commands:
+  eval expression [file ...]    evaluate an expression against JSON, YAML or NDJSON input
+  fmt [expression]              print an expression in canonical form
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command named by the first argument and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+	switch args[0] {
+	case "eval":
+		return runEval(args[1:], stdin, stdout, stderr)
+	case "fmt":
+		return runFmt(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	fmt.Fprintf(stderr, "analyze: unknown command %q\n\n%s", args[0], usage)
+	return exitError
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tt := []struct {
+		name   string
+		args   []string
+		stdin  string
+		status int
+		stdout string
+		stderr string
+	}{
+		{
+			name:   "no command",
+			status: exitError,
+			stderr: "usage: analyze",
+		},
+		{
+			name:   "unknown command",
+			args:   []string{"lint"},
+			status: exitError,
+			stderr: `unknown command "lint"`,
+		},
+		{
+			name:   "fmt argument",
+			args:   []string{"fmt", `$a==1&&@len( $b )>0`},
+			stdout: "$a == 1 && @len($b) > 0\n",
+		},
+		{
+			name:   "fmt stdin",
+			args:   []string{"fmt"},
+			stdin:  "$a  in  [1,2]\n",
+			stdout: "$a in [1, 2]\n",
+		},
+		{
+			name:   "fmt syntax error",
+			args:   []string{"fmt", `$a ==`},
+			status: exitError,
+			stderr: "analyze fmt:",
+		},
+		{
+			name:   "fmt comments",
+			args:   []string{"fmt"},
+			stdin:  "// replicas\n$a>=2 &&  // at least\n$b\n",
+			stdout: "// replicas\n$a >= 2\n\t// at least\n\t&& $b\n",
+		},
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"deployment.json": `{"spec": {"replicas": 3, "image": "nginx:1.21"}}`,
+		"deployment.yaml": "spec:\n  replicas: 1\n  image: nginx:1.20\n",
+		"pods.ndjson":     "{\"name\": \"web-0\", \"ready\": true}\n\n{\"name\": \"web-1\", \"ready\": false}\n",
+		"baseline.yaml":   "image: nginx:1.21\n",
+		"invalid.json":    `{"spec": `,
+	}
+	for name, content := range files {
+		require.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+	tt = append(tt, []struct {
+		name   string
+		args   []string
+		stdin  string
+		status int
+		stdout string
+		stderr string
+	}{
+		{
+			name:   "eval true",
+			args:   []string{"eval", `$spec.replicas >= 2`, path("deployment.json")},
+			stdout: "true\n",
+		},
+		{
+			name:   "eval false",
+			args:   []string{"eval", `$spec.replicas >= 2`, path("deployment.json"), path("deployment.yaml")},
+			status: exitFalse,
+			stdout: "true\nfalse\n",
+		},
+		{
+			name:   "eval value",
+			args:   []string{"eval", `@keys($spec)`, path("deployment.yaml")},
+			stdout: "[\"image\",\"replicas\"]\n",
+		},
+		{
+			name:   "eval ndjson",
+			args:   []string{"eval", `$ready`, path("pods.ndjson")},
+			status: exitFalse,
+			stdout: "true\nfalse\n",
+		},
+		{
+			name:   "eval ndjson error line",
+			args:   []string{"eval", `$ready ? true : $name > 1`, path("pods.ndjson")},
+			status: exitError,
+			stdout: "true\n",
+			stderr: "pods.ndjson:3: ",
+		},
+		{
+			name:   "eval stdin",
+			args:   []string{"eval", "-format", "yaml", `$a == 1`},
+			stdin:  "a: 1\n",
+			stdout: "true\n",
+		},
+		{
+			name:   "eval var",
+			args:   []string{"eval", "--var", "baseline=" + path("baseline.yaml"), "--var", "pods=" + path("pods.ndjson"), `$spec.image == $baseline.image && @len($pods) == 2`, path("deployment.json")},
+			stdout: "true\n",
+		},
+		{
+			name:   "eval coerce",
+			args:   []string{"eval", "-coerce", `$pid > 100`},
+			stdin:  `{"pid": "1234"}`,
+			stdout: "true\n",
+		},
+		{
+			name:   "eval error",
+			args:   []string{"eval", `$spec.replicas > "x"`, path("deployment.json")},
+			status: exitError,
+			stderr: "deployment.json: type mismatch",
+		},
+		{
+			name:   "eval undeclared function",
+			args:   []string{"eval", `@nope($a)`},
+			stdin:  `{}`,
+			status: exitError,
+			stderr: `"@nope"`,
+		},
+		{
+			name:   "eval invalid input",
+			args:   []string{"eval", `$a`, path("invalid.json")},
+			status: exitError,
+			stderr: "invalid.json:",
+		},
+		{
+			name:   "eval missing file",
+			args:   []string{"eval", `$a`, path("missing.json")},
+			status: exitError,
+			stderr: "missing.json",
+		},
+		{
+			name:   "eval invalid var",
+			args:   []string{"eval", "--var", "baseline", `$a`},
+			status: exitError,
+			stderr: "expected name=file",
+		},
+	}...)
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr)
+			require.Equal(t, tc.status, status, stderr.String())
+			require.Equal(t, tc.stdout, stdout.String())
+			require.Contains(t, stderr.String(), tc.stderr)
+		})
+	}
+}
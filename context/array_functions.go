@@ -0,0 +1,319 @@
+package context
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/internal/ast"
+)
+
+// None of the array functions modify the arrays passed to them, they return new arrays.
+
+// @sort(arr, path, direction) returns arr sorted in ascending order. path and direction are optional, if path is
+// given elements are ordered by the field it refers to, for example @sort($processes, "resident_size", "desc"). An
+// empty path orders elements by their own value. direction is either "asc" or "desc". Numbers and strings are ordered
+// by value, when values of different types are compared nil sorts first, followed by booleans, numbers, strings,
+// arrays and objects. The sort is stable.
+func _sort(args []interface{}) (interface{}, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, errors.New(errors.SyntaxError, "wrong number of arguments for sort, expected 1 to 3 got %d", len(args))
+	}
+	arr, err := arrayArg("sort", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	var path string
+	if len(args) > 1 {
+		if path, err = stringArg("sort", args, 1); err != nil {
+			return nil, err
+		}
+	}
+	descending := false
+	if len(args) > 2 {
+		direction, err := stringArg("sort", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		switch direction {
+		case "asc":
+		case "desc":
+			descending = true
+		default:
+			return nil, errors.New(errors.InvalidArgument, "sort direction must be \"asc\" or \"desc\" got %q", direction)
+		}
+	}
+	keys := make([]interface{}, len(arr))
+	for i, elt := range arr {
+		if keys[i], err = lookupPath(elt, path); err != nil {
+			return nil, err
+		}
+	}
+	indexes := make([]int, len(arr))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		c := compareValues(keys[indexes[i]], keys[indexes[j]])
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+	result := make([]interface{}, len(arr))
+	for i, index := range indexes {
+		result[i] = arr[index]
+	}
+	return result, nil
+}
+
+// @reverse(arr) returns the elements of arr in reverse order.
+func _reverse(args []interface{}) (interface{}, error) {
+	if err := checkArgs("reverse", args, 1); err != nil {
+		return nil, err
+	}
+	arr, err := arrayArg("reverse", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(arr))
+	for i, elt := range arr {
+		result[len(arr)-1-i] = elt
+	}
+	return result, nil
+}
+
+// @flatten(arr, depth) replaces arrays nested in arr with their elements. depth is optional and limits how many levels
+// of nesting are flattened, by default all levels are.
+func _flatten(args []interface{}) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, errors.New(errors.SyntaxError, "wrong number of arguments for flatten, expected 1 or 2 got %d", len(args))
+	}
+	arr, err := arrayArg("flatten", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	depth := -1
+	if len(args) > 1 {
+		if depth, err = intArg("flatten", args, 1); err != nil {
+			return nil, err
+		}
+		if depth < 0 {
+			return nil, errors.New(errors.InvalidArgument, "flatten depth must not be negative got %d", depth)
+		}
+	}
+	return flatten([]interface{}{}, arr, depth), nil
+}
+
+func flatten(result, arr []interface{}, depth int) []interface{} {
+	for _, elt := range arr {
+		if nested, ok := elt.([]interface{}); ok && depth != 0 {
+			result = flatten(result, nested, depth-1)
+			continue
+		}
+		result = append(result, elt)
+	}
+	return result
+}
+
+// @distinct(arr) returns arr with duplicate elements removed, it is another name for @unique.
+func _distinct(args []interface{}) (interface{}, error) {
+	return unique("distinct", args)
+}
+
+// @first(arr) returns the first element of arr or nil if arr is empty.
+func _first(args []interface{}) (interface{}, error) {
+	if err := checkArgs("first", args, 1); err != nil {
+		return nil, err
+	}
+	arr, err := arrayArg("first", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr) == 0 {
+		return nil, nil
+	}
+	return arr[0], nil
+}
+
+// @last(arr) returns the last element of arr or nil if arr is empty.
+func _last(args []interface{}) (interface{}, error) {
+	if err := checkArgs("last", args, 1); err != nil {
+		return nil, err
+	}
+	arr, err := arrayArg("last", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(arr) == 0 {
+		return nil, nil
+	}
+	return arr[len(arr)-1], nil
+}
+
+// @take(arr, n) returns the first n elements of arr, or all of them if arr has fewer than n elements.
+func _take(args []interface{}) (interface{}, error) {
+	arr, n, err := arrayAndCount("take", args)
+	if err != nil {
+		return nil, err
+	}
+	return append([]interface{}{}, arr[:n]...), nil
+}
+
+// @skip(arr, n) returns the elements of arr after the first n.
+func _skip(args []interface{}) (interface{}, error) {
+	arr, n, err := arrayAndCount("skip", args)
+	if err != nil {
+		return nil, err
+	}
+	return append([]interface{}{}, arr[n:]...), nil
+}
+
+// arrayAndCount checks the arguments of take and skip, the count returned is limited to the length of the array.
+func arrayAndCount(name string, args []interface{}) ([]interface{}, int, error) {
+	if err := checkArgs(name, args, 2); err != nil {
+		return nil, 0, err
+	}
+	arr, err := arrayArg(name, args, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := intArg(name, args, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n < 0 {
+		return nil, 0, errors.New(errors.InvalidArgument, "%s count must not be negative got %d", name, n)
+	}
+	if n > len(arr) {
+		n = len(arr)
+	}
+	return arr, n, nil
+}
+
+// @zip(arr1, arr2, ... arrN) returns an array of arrays where the i-th array holds the i-th element of each argument.
+// The result is as long as the shortest argument.
+func _zip(args []interface{}) (interface{}, error) {
+	arrs, err := arrayArgs("zip", args)
+	if err != nil {
+		return nil, err
+	}
+	length := len(arrs[0])
+	for _, arr := range arrs[1:] {
+		if len(arr) < length {
+			length = len(arr)
+		}
+	}
+	result := make([]interface{}, 0, length)
+	for i := 0; i < length; i++ {
+		tuple := make([]interface{}, 0, len(arrs))
+		for _, arr := range arrs {
+			tuple = append(tuple, arr[i])
+		}
+		result = append(result, tuple)
+	}
+	return result, nil
+}
+
+// maxRangeLength is the most numbers @range will return.
+const maxRangeLength = 1000000
+
+// @range(end) returns the numbers 0 to end - 1, @range(start, end, step) returns the numbers from start up to but not
+// including end incrementing by step. step is optional and defaults to 1, it can be negative to count down. A range
+// of more than maxRangeLength numbers is an error.
+func _range(args []interface{}) (interface{}, error) {
+	if len(args) < 1 || len(args) > 3 {
+		return nil, errors.New(errors.SyntaxError, "wrong number of arguments for range, expected 1 to 3 got %d", len(args))
+	}
+	bounds := []int{0, 0, 1}
+	if len(args) == 1 {
+		end, err := intArg("range", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		bounds[1] = end
+	}
+	for i := 0; len(args) > 1 && i < len(args); i++ {
+		n, err := intArg("range", args, i)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = n
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return nil, errors.New(errors.InvalidArgument, "range step must not be zero")
+	}
+	var n int
+	switch {
+	case step > 0 && end > start:
+		n = (end - start + step - 1) / step
+	case step < 0 && end < start:
+		n = (start - end - step - 1) / -step
+	}
+	if n > maxRangeLength {
+		return nil, errors.New(errors.InvalidArgument, "range of %d numbers is longer than the maximum of %d", n, maxRangeLength)
+	}
+	result := make([]interface{}, 0, n)
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		result = append(result, float64(i))
+	}
+	return result, nil
+}
+
+// lookupPath returns the value of the field path refers to in elt, or elt itself if path is empty.
+func lookupPath(elt interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return elt, nil
+	}
+	return ast.Lookup(elt, path)
+}
+
+// compareValues orders two values returning -1, 0 or 1. Values of different types are ordered by typeOrder, numbers
+// compare by value whatever their Go representation.
+func compareValues(l, r interface{}) int {
+	l, r = ast.Normalize(l), ast.Normalize(r)
+	lo, ro := typeOrder(l), typeOrder(r)
+	if lo != ro {
+		return sign(lo - ro)
+	}
+	switch lt := l.(type) {
+	case bool:
+		rt := r.(bool)
+		switch {
+		case !lt && rt:
+			return -1
+		case lt && !rt:
+			return 1
+		}
+	case float64:
+		rt := r.(float64)
+		switch {
+		case lt < rt:
+			return -1
+		case lt > rt:
+			return 1
+		}
+	case string:
+		return strings.Compare(lt, r.(string))
+	}
+	return 0
+}
+
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	case []interface{}:
+		return 4
+	case map[string]interface{}:
+		return 5
+	}
+	return 6
+}
@@ -3,6 +3,7 @@ package context
 import (
 	"github.com/murphybytes/analyze/errors"
 	"github.com/murphybytes/analyze/internal/ast"
+	"math"
 	"regexp"
 )
 
@@ -21,8 +22,9 @@ func _len(args []interface{}) (interface{}, error) {
 // @select(arr, expression) returns a subset of arr such that elements of the subset are such that expression is true.
 // note that each element can be referenced in the expression by a variable, for example: "$foo == 3" would return each
 // element in an array that was equal to three.  You would use $foo.bar == "complete" to reference the field "bar" in an
-// array of objects, returning all objects where "bar" == "complete"
-func _select(args []interface{}) (interface{}, error) {
+// array of objects, returning all objects where "bar" == "complete". If no element matches an empty array is returned
+// so the result can be passed to functions like @len.
+func (c *Context) _select(args []interface{}) (interface{}, error) {
 	if len(args) != 2 {
 		return nil, errors.New(errors.SyntaxError, "wrong number of arguments for select, expected 2 got %d", len(args))
 	}
@@ -39,10 +41,10 @@ func _select(args []interface{}) (interface{}, error) {
 	if err := parser.ParseString("", expression, &ast); err != nil {
 		return nil, err
 	}
-	var selected []interface{}
+	selected := []interface{}{}
 
 	for _, elt := range arr {
-		ctx, err := New(elt)
+		ctx, err := c.child(elt)
 		if err != nil {
 			return nil, err
 		}
@@ -50,6 +52,9 @@ func _select(args []interface{}) (interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
+		if result.Bool == nil {
+			return nil, errors.New(errors.TypeMismatch, "select predicate %q must evaluate to a bool", expression)
+		}
 		if bool(*result.Bool) {
 			selected = append(selected, elt)
 		}
@@ -63,23 +68,15 @@ func _array(args []interface{}) (interface{}, error) {
 	return args, nil
 }
 
-// @in(arr, val) if value is in array returns true
+// @in(arr, val) if value is in array returns true. Elements are compared with the same deep equality used by the ==
+// operator so arrays and objects can be searched for as well as scalars.
 func _in(args []interface{}) (interface{}, error) {
 	if len(args) != 2 {
 		return nil, errors.New(errors.SyntaxError, "wrong number of arguments for in, expected 2, got %d", len(args))
 	}
 	switch t := args[0].(type) {
 	case []interface{}:
-		for _, elt := range t {
-			match, err := compare(elt, args[1])
-			if err != nil {
-				return nil, err
-			}
-			if match {
-				return true, nil
-			}
-		}
-		return false, nil
+		return contains(t, args[1]), nil
 	}
 	return nil, errors.New(errors.TypeMismatch, "expected array for first argument for in function")
 }
@@ -101,8 +98,10 @@ func _has(args []interface{})(interface{},error){
 	return nil, errors.New(errors.TypeMismatch, "expected object for first argument of has function")
 }
 
-// @match(string, regex-string) returns true if string matches regex-string of the form /regular expression/
-func _match(args []interface{})(interface{},error){
+// @match(string, regex) returns true if string matches regex. regex is either a literal of the form
+// /regular expression/ which is compiled when the expression is parsed, or a string holding a pattern that is
+// compiled when the expression is evaluated and cached by the context.
+func (c *Context) _match(args []interface{})(interface{},error){
 	if len(args) != 2 {
 		return nil, errors.New(errors.SyntaxError, "match expects 2 arguments")
 	}
@@ -110,49 +109,67 @@ func _match(args []interface{})(interface{},error){
 	if !ok {
 		return nil, errors.New(errors.TypeMismatch, "match expects string argument")
 	}
-	exp, ok := args[1].(string)
-	if !ok {
-		return nil, errors.New(errors.TypeMismatch, "match expects string argument 2")
-	}
-	regex, err := regexp.Compile(exp)
+	regex, err := c.regexpArg("match", args, 1)
 	if err != nil {
 		return nil, err
 	}
 	return regex.MatchString(val), nil
 }
 
-func compare(l, r interface{}) (bool, error) {
-	switch t := l.(type) {
-	case int:
-		if rt, ok := r.(int); ok {
-			return t == rt, nil
-		}
-		return false, errors.New(errors.TypeMismatch, "type mismatch")
-	case *int:
-		if rt, ok := r.(*int); ok {
-			return *t == *rt, nil
-		}
-		return false, errors.New(errors.TypeMismatch, "type mismatch")
-	case float64:
-		if rt, ok := r.(float64); ok {
-			return t == rt, nil
-		}
-		return false, errors.New(errors.TypeMismatch, "type mismatch")
-	case *float64:
-		if rt, ok := r.(*float64); ok {
-			return *t == *rt, nil
-		}
-		return false, errors.New(errors.TypeMismatch, "type mismatch")
+// checkArgs returns an error if a builtin named name was not called with exactly n arguments.
+func checkArgs(name string, args []interface{}, n int) error {
+	if len(args) != n {
+		return errors.New(errors.SyntaxError, "wrong number of arguments for %s, expected %d got %d", name, n, len(args))
+	}
+	return nil
+}
+
+// stringArg returns the argument at index i as a string or a type mismatch error.
+func stringArg(name string, args []interface{}, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", errors.New(errors.TypeMismatch, "expected string got %T for argument %d of %s", args[i], i+1, name)
+	}
+	return s, nil
+}
+
+// arrayArg returns the argument at index i as an array or a type mismatch error.
+func arrayArg(name string, args []interface{}, i int) ([]interface{}, error) {
+	arr, ok := args[i].([]interface{})
+	if !ok {
+		return nil, errors.New(errors.TypeMismatch, "expected array got %T for argument %d of %s", args[i], i+1, name)
+	}
+	return arr, nil
+}
+
+// regexpArg returns the argument at index i as a compiled regular expression, the argument is either a regular
+// expression literal or a string pattern.
+func (c *Context) regexpArg(name string, args []interface{}, i int) (*regexp.Regexp, error) {
+	switch t := args[i].(type) {
+	case *regexp.Regexp:
+		return t, nil
 	case string:
-		if rt, ok := r.(string); ok {
-			return t == rt, nil
-		}
-		return false, errors.New(errors.TypeMismatch, "type mismatch")
-	case *string:
-		if rt, ok := r.(*string); ok {
-			return *t == *rt, nil
+		regex, err := c.CompileRegexp(t)
+		if err != nil {
+			return nil, errors.New(errors.InvalidArgument, "invalid regular expression %q for argument %d of %s: %s", t, i+1, name, err)
 		}
-		return false, errors.New(errors.TypeMismatch, "type mismatch")
+		return regex, nil
+	}
+	return nil, errors.New(errors.TypeMismatch, "expected regular expression got %T for argument %d of %s", args[i], i+1, name)
+}
+
+// maxWholeNumber is the largest magnitude intArg accepts, 2^53 is the largest integer a float64 holds exactly.
+const maxWholeNumber = 1 << 53
+
+// intArg returns the argument at index i as an int, the argument must be a whole number no larger in magnitude than
+// maxWholeNumber.
+func intArg(name string, args []interface{}, i int) (int, error) {
+	f, ok := args[i].(float64)
+	if !ok || f != math.Trunc(f) || math.IsInf(f, 0) {
+		return 0, errors.New(errors.TypeMismatch, "expected whole number got %v for argument %d of %s", args[i], i+1, name)
+	}
+	if math.Abs(f) > maxWholeNumber {
+		return 0, errors.New(errors.InvalidArgument, "%v is out of range for argument %d of %s", f, i+1, name)
 	}
-	return false, errors.New(errors.UnsupportedType, "unsupported type for in function")
+	return int(f), nil
 }
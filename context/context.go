@@ -16,6 +16,9 @@ type Option func(*Context) error
 type Context struct {
 	data      interface{}
 	functions functionTable
+	regexps   *regexpCache
+	coerce    bool
+	vars      map[string]interface{}
 }
 
 // Data returns data that maps to variables defined in expressions.
@@ -25,10 +28,51 @@ func(c Context) Data() interface{} {
 
 // Func returns a named function.
 func(c Context) Func(name string)(ast.UserDefinedFunc, bool){
+	if fn, ok := contextFunctions[name]; ok {
+		return func(args []interface{}) (interface{}, error) {
+			return fn(&c, args)
+		}, true
+	}
 	fn, ok := c.functions[name]
 	return fn, ok
 }
 
+// contextFunctions are builtin functions that evaluate nested expressions, they are bound to the context that calls
+// them so names bound by let expressions and the Var option are visible in the nested expression.
+var contextFunctions = map[string]func(*Context, []interface{}) (interface{}, error){
+	"@select": (*Context)._select,
+}
+
+// Bind returns a copy of the context with value bound to name, let expressions use it to bind their names so they
+// are visible in the body of the let and in @select predicates called from it.
+func (c Context) Bind(name string, value interface{}) ast.Context {
+	vars := make(map[string]interface{}, len(c.vars)+1)
+	for k, v := range c.vars {
+		vars[k] = v
+	}
+	vars[name] = value
+	c.vars = vars
+	return &c
+}
+
+// CompileRegexp compiles a regular expression pattern built while evaluating an expression, compiled patterns are
+// cached by the context.
+func (c Context) CompileRegexp(pattern string) (*regexp.Regexp, error) {
+	return c.regexps.compile(pattern)
+}
+
+// CoerceNumericStrings reports whether strings holding numbers are converted when compared with numbers, see the
+// CoerceNumericStrings option.
+func (c Context) CoerceNumericStrings() bool {
+	return c.coerce
+}
+
+// Lookup returns the value bound to name by the Var option.
+func (c Context) Lookup(name string) (interface{}, bool) {
+	val, ok := c.vars[name]
+	return val, ok
+}
+
 var functionNameMatcher = regexp.MustCompile(`^@[A-Za-z0-9_]\w*`)
 
 // Func pass a user defined function to a new context.  The name for the function must be prefaced by '@' for
@@ -38,6 +82,9 @@ func Func(name string, definedFunc ast.UserDefinedFunc) Option {
 		if !functionNameMatcher.MatchString(name) {
 			return errors.New(errors.InvalidFunction, "%q is not a valid function name", name)
 		}
+		if _, ok := contextFunctions[name]; ok {
+			return errors.New(errors.DuplicateFunction, "function name %q already in use", name)
+		}
 		if _, ok := ctx.functions[name]; ok {
 			return errors.New(errors.DuplicateFunction, "function name %q already in use", name)
 		}
@@ -46,6 +93,54 @@ func Func(name string, definedFunc ast.UserDefinedFunc) Option {
 	}
 }
 
+// RegexpCacheSize sets the number of compiled regular expressions kept by a context, the default is
+// DefaultRegexpCacheSize. Patterns that are computed when an expression is evaluated, @match($name, $pattern) for
+// example, are compiled once and reused until they are evicted. A size of zero disables the cache.
+func RegexpCacheSize(size int) Option {
+	return func(ctx *Context) error {
+		if size < 0 {
+			return errors.New(errors.InvalidArgument, "regular expression cache size must not be negative, got %d", size)
+		}
+		ctx.regexps = newRegexpCache(size)
+		return nil
+	}
+}
+
+var varNameMatcher = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// Var binds value to name so expressions can refer to it as $name alongside the context data, for example to compare
+// the data with a reference document, $spec.image == $baseline.image. Names bound by Var take precedence over fields
+// of the data with the same name and can be shadowed by let expressions. Like let bindings they are visible in
+// @select predicates, where they also take precedence over the fields of each element.
+func Var(name string, value interface{}) Option {
+	return func(ctx *Context) error {
+		if !varNameMatcher.MatchString(name) {
+			return errors.New(errors.InvalidArgument, "%q is not a valid variable name", name)
+		}
+		if _, ok := ctx.vars[name]; ok {
+			return errors.New(errors.InvalidArgument, "variable %q is already bound", name)
+		}
+		if err := validate(value); err != nil {
+			return err
+		}
+		if ctx.vars == nil {
+			ctx.vars = make(map[string]interface{})
+		}
+		ctx.vars[name] = value
+		return nil
+	}
+}
+
+// CoerceNumericStrings makes comparisons between a number and a string holding a decimal number compare numerically,
+// so with data from tools that report every value as a string $pid > 100 works without @number. The string must parse
+// strictly as @number would, otherwise the comparison fails with a type mismatch as usual.
+func CoerceNumericStrings() Option {
+	return func(ctx *Context) error {
+		ctx.coerce = true
+		return nil
+	}
+}
+
 // New creates a new context with data that can be referenced in variables in expressions.  User defined functions
 // can optionally be passed as well.
 func New(data interface{}, options ...Option) (*Context, error) {
@@ -55,16 +150,80 @@ func New(data interface{}, options ...Option) (*Context, error) {
 
 	ctx := Context{
 		data:      data,
+		regexps:   newRegexpCache(DefaultRegexpCacheSize),
 	}
 
 	// builtin functions
 	ctx.functions = functionTable{
 		"@len": _len,
-		"@select": _select,
 		"@in": _in ,
 		"@array": _array,
 		"@has": _has,
-		"@match": _match,
+		"@match": ctx._match,
+		"@capture": ctx._capture,
+		"@find_all": ctx._findAll,
+		"@replace_re": ctx._replaceRe,
+		"@glob": ctx._glob,
+		"@fnmatch": ctx._fnmatch,
+		// objects
+		"@keys":      _keys,
+		"@values":    _values,
+		"@entries":   _entries,
+		"@type":      _type,
+		"@is_array":  _isArray,
+		"@is_object": _isObject,
+		// networking
+		"@ip_in_cidr":   _ipInCidr,
+		"@is_private":   _isPrivate,
+		"@is_loopback":  _isLoopback,
+		"@is_ip":        _isIP,
+		"@ip_version":   _ipVersion,
+		"@cidr_overlap": _cidrOverlap,
+		// versions
+		"@semver_compare":   _semverCompare,
+		"@semver_satisfies": _semverSatisfies,
+		"@version_compare":  _versionCompare,
+		// sets
+		"@union":      _union,
+		"@intersect":  _intersect,
+		"@difference": _difference,
+		"@unique":     _unique,
+		"@subset":     _subset,
+		"@superset":   _superset,
+		// arrays
+		"@sort":     _sort,
+		"@reverse":  _reverse,
+		"@flatten":  _flatten,
+		"@distinct": _distinct,
+		"@first":    _first,
+		"@last":     _last,
+		"@take":     _take,
+		"@skip":     _skip,
+		"@zip":      _zip,
+		"@range":    _range,
+		// grouping
+		"@group_by": _groupBy,
+		"@count_by": _countBy,
+		"@index_by": _indexBy,
+		// joins
+		"@join":   _join,
+		"@lookup": _lookup,
+		// conversions
+		"@number": _number,
+		"@int":    _int,
+		"@string": _string,
+		"@bool":   _bool,
+		// encodings
+		"@base64_decode": _base64Decode,
+		"@base64_encode": _base64Encode,
+		"@sha256":        _sha256,
+		"@md5":           _md5,
+		"@hex":           _hex,
+		"@url_decode":    _urlDecode,
+		"@json_parse":    _jsonParse,
+		"@yaml_parse":    _yamlParse,
+		"@parse_json":    _parseJSON,
+		"@parse_yaml":    _parseYAML,
 	}
 
 	for _, opt := range options {
@@ -76,6 +235,21 @@ func New(data interface{}, options ...Option) (*Context, error) {
 	return &ctx, nil
 }
 
+// child creates a context used to evaluate a nested expression such as the predicate passed to @select. It has its
+// own data and shares functions, cached regular expressions and bound variables with its parent.
+func (c *Context) child(data interface{}) (*Context, error) {
+	if err := validate(data); err != nil {
+		return nil, err
+	}
+	return &Context{
+		data:      data,
+		functions: c.functions,
+		regexps:   c.regexps,
+		coerce:    c.coerce,
+		vars:      c.vars,
+	}, nil
+}
+
 func validate(data interface{}) error {
 	switch t := data.(type) {
 	case int:
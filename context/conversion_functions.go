@@ -0,0 +1,102 @@
+package context
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/internal/ast"
+)
+
+// Data collected by tools such as osquery often represents every value as a string, the conversion functions turn
+// those strings into numbers and booleans so they can be compared as such, @number($pid) > 100 for instance. Parsing
+// is strict, leading or trailing spaces and trailing garbage are errors rather than being ignored.
+
+// @number(val) converts the string val to a number, numbers are returned unchanged.
+func _number(args []interface{}) (interface{}, error) {
+	if err := checkArgs("number", args, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case float64:
+		return t, nil
+	case string:
+		if f, ok := ast.ParseNumber(t); ok {
+			return f, nil
+		}
+		return nil, errors.New(errors.InvalidArgumentType, "can't convert %q to a number", t)
+	}
+	return nil, conversionError("number", args[0])
+}
+
+// @int(val) converts val to a whole number. Numbers are truncated towards zero, strings must be decimal integers such
+// as "42" or "-7".
+func _int(args []interface{}) (interface{}, error) {
+	if err := checkArgs("int", args, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case float64:
+		return math.Trunc(t), nil
+	case string:
+		n, err := strconv.ParseInt(t, 10, 64)
+		if err != nil {
+			return nil, errors.New(errors.InvalidArgumentType, "can't convert %q to an int", t)
+		}
+		return float64(n), nil
+	}
+	return nil, conversionError("int", args[0])
+}
+
+// @string(val) converts a number or bool to a string, strings are returned unchanged. Numbers are formatted without
+// trailing zeros so @string(8080) returns "8080".
+func _string(args []interface{}) (interface{}, error) {
+	if err := checkArgs("string", args, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case string:
+		return t, nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	}
+	return nil, conversionError("string", args[0])
+}
+
+// @bool(val) converts val to a bool. Strings accepted are "1", "t", "true", "0", "f" and "false" in any case, the
+// numbers 1 and 0 are also accepted. Bools are returned unchanged.
+func _bool(args []interface{}) (interface{}, error) {
+	if err := checkArgs("bool", args, 1); err != nil {
+		return nil, err
+	}
+	switch t := args[0].(type) {
+	case bool:
+		return t, nil
+	case string:
+		b, err := strconv.ParseBool(strings.ToLower(t))
+		if err != nil {
+			return nil, errors.New(errors.InvalidArgumentType, "can't convert %q to a bool", t)
+		}
+		return b, nil
+	case float64:
+		switch t {
+		case 0:
+			return false, nil
+		case 1:
+			return true, nil
+		}
+		return nil, errors.New(errors.InvalidArgumentType, "can't convert %v to a bool, expected 0 or 1", t)
+	}
+	return nil, conversionError("bool", args[0])
+}
+
+func conversionError(name string, v interface{}) error {
+	typ, err := typeName(v)
+	if err != nil {
+		return err
+	}
+	return errors.New(errors.InvalidArgumentType, "can't convert %s to %s", typ, name)
+}
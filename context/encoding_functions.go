@@ -0,0 +1,133 @@
+package context
+
+import (
+	"crypto/md5" //nolint
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/internal/document"
+)
+
+// @base64_decode(str) decodes the standard, padded base64 encoding used by Kubernetes Secrets.
+// @base64_decode($secret.data.password) returns the secret in clear text.
+func _base64Decode(args []interface{}) (interface{}, error) {
+	s, err := singleStringArg("base64_decode", args)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New(errors.InvalidArgument, "invalid base64 argument for base64_decode: %s", err)
+	}
+	return string(b), nil
+}
+
+// @base64_encode(str) returns the standard, padded base64 encoding of str.
+func _base64Encode(args []interface{}) (interface{}, error) {
+	s, err := singleStringArg("base64_encode", args)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.EncodeToString([]byte(s)), nil
+}
+
+// @sha256(str) returns the SHA-256 digest of str as a lower case hex string.
+func _sha256(args []interface{}) (interface{}, error) {
+	s, err := singleStringArg("sha256", args)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// @md5(str) returns the MD5 digest of str as a lower case hex string. MD5 is broken as a cryptographic hash, use it
+// only to compare with digests published elsewhere.
+func _md5(args []interface{}) (interface{}, error) {
+	s, err := singleStringArg("md5", args)
+	if err != nil {
+		return nil, err
+	}
+	sum := md5.Sum([]byte(s)) //nolint
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// @hex(str) returns the bytes of str encoded as lower case hex.
+func _hex(args []interface{}) (interface{}, error) {
+	s, err := singleStringArg("hex", args)
+	if err != nil {
+		return nil, err
+	}
+	return hex.EncodeToString([]byte(s)), nil
+}
+
+// @url_decode(str) decodes a URL query component, %XX escapes are replaced by the bytes they encode and + by a space.
+func _urlDecode(args []interface{}) (interface{}, error) {
+	s, err := singleStringArg("url_decode", args)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := url.QueryUnescape(s)
+	if err != nil {
+		return nil, errors.New(errors.InvalidArgument, "invalid argument for url_decode: %s", err)
+	}
+	return decoded, nil
+}
+
+// @json_parse(str) parses the JSON document in str returning an object, array or scalar value that can be used like
+// any other value, @json_parse($cm.data["app.json"]) for instance.
+func _jsonParse(args []interface{}) (interface{}, error) {
+	return jsonParse("json_parse", args)
+}
+
+// @parse_json(str) is another name for @json_parse.
+func _parseJSON(args []interface{}) (interface{}, error) {
+	return jsonParse("parse_json", args)
+}
+
+// jsonParse implements @json_parse and @parse_json, name is the function reported in errors.
+func jsonParse(name string, args []interface{}) (interface{}, error) {
+	s, err := singleStringArg(name, args)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := document.ParseJSON([]byte(s))
+	if err != nil {
+		return nil, errors.New(errors.InvalidArgument, "invalid JSON argument for %s: %s", name, err)
+	}
+	return doc, nil
+}
+
+// @yaml_parse(str) parses the YAML document in str, only the first document of a multi document stream is returned.
+// Mapping keys that aren't strings are converted to strings and integers to numbers.
+func _yamlParse(args []interface{}) (interface{}, error) {
+	return yamlParse("yaml_parse", args)
+}
+
+// @parse_yaml(str) is another name for @yaml_parse.
+func _parseYAML(args []interface{}) (interface{}, error) {
+	return yamlParse("parse_yaml", args)
+}
+
+// yamlParse implements @yaml_parse and @parse_yaml, name is the function reported in errors.
+func yamlParse(name string, args []interface{}) (interface{}, error) {
+	s, err := singleStringArg(name, args)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := document.ParseYAML([]byte(s))
+	if err != nil {
+		return nil, errors.New(errors.InvalidArgument, "invalid YAML argument for %s: %s", name, err)
+	}
+	return doc, nil
+}
+
+func singleStringArg(name string, args []interface{}) (string, error) {
+	if err := checkArgs(name, args, 1); err != nil {
+		return "", err
+	}
+	return stringArg(name, args, 0)
+}
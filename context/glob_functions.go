@@ -0,0 +1,123 @@
+package context
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/murphybytes/analyze/errors"
+)
+
+// @glob(str, pattern) returns true if the path str matches the glob pattern. * matches any sequence of characters
+// except /, ** matches any sequence of characters including / so "/tmp/**" matches every file under /tmp and "**/"
+// matches zero or more directories, ? matches a single character except /, [abc] and [a-z] match a character in a
+// class and [!abc] or [^abc] one that isn't. A backslash escapes the character that follows it.
+func (c *Context) _glob(args []interface{}) (interface{}, error) {
+	return c.globMatch("glob", args, true)
+}
+
+// @fnmatch(str, pattern) matches str against a shell wildcard pattern as fnmatch(3) does without any flags. Unlike
+// glob, * and ? also match /, so "registry.corp/*" matches "registry.corp/team/nginx:1.21".
+func (c *Context) _fnmatch(args []interface{}) (interface{}, error) {
+	return c.globMatch("fnmatch", args, false)
+}
+
+func (c *Context) globMatch(name string, args []interface{}, pathname bool) (interface{}, error) {
+	if err := checkArgs(name, args, 2); err != nil {
+		return nil, err
+	}
+	s, err := stringArg(name, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := stringArg(name, args, 1)
+	if err != nil {
+		return nil, err
+	}
+	source, err := globToRegexp(pattern, pathname)
+	if err != nil {
+		return nil, errors.New(errors.InvalidArgument, "invalid pattern %q for %s: %s", pattern, name, err)
+	}
+	regex, err := c.CompileRegexp(source)
+	if err != nil {
+		return nil, errors.New(errors.InvalidArgument, "invalid pattern %q for %s: %s", pattern, name, err)
+	}
+	return regex.MatchString(s), nil
+}
+
+// globToRegexp translates a wildcard pattern to an anchored regular expression. If pathname is true wildcards don't
+// match / except for **.
+func globToRegexp(pattern string, pathname bool) (string, error) {
+	many, one := `.*`, `.`
+	if pathname {
+		many, one = `[^/]*`, `[^/]`
+	}
+	var b strings.Builder
+	b.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			if !pathname || !strings.HasPrefix(pattern[i:], "**") {
+				b.WriteString(many)
+				continue
+			}
+			i++
+			if strings.HasPrefix(pattern[i+1:], "/") {
+				i++
+				b.WriteString(`(?:.*/)?`)
+				continue
+			}
+			b.WriteString(`.*`)
+		case '?':
+			b.WriteString(one)
+		case '[':
+			end, class, err := globClass(pattern, i, pathname)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(class)
+			i = end
+		case '\\':
+			if i+1 == len(pattern) {
+				return "", errors.NewSyntaxError("trailing backslash")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString(`$`)
+	return b.String(), nil
+}
+
+// globClass translates the character class starting at pattern[start] returning the index of the closing bracket and
+// the equivalent regular expression class.
+func globClass(pattern string, start int, pathname bool) (int, string, error) {
+	i := start + 1
+	negate := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+	if negate {
+		i++
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	if negate {
+		b.WriteString("^")
+		if pathname {
+			b.WriteString("/")
+		}
+	}
+	// a ] immediately after the opening bracket is part of the class
+	for first := true; i < len(pattern); i, first = i+1, false {
+		c := pattern[i]
+		if c == ']' && !first {
+			b.WriteString("]")
+			return i, b.String(), nil
+		}
+		switch c {
+		case '\\', '[', ']', '^':
+			b.WriteString(`\`)
+		}
+		b.WriteByte(c)
+	}
+	return 0, "", errors.NewSyntaxError("unterminated character class")
+}
@@ -0,0 +1,102 @@
+package context
+
+import (
+	"strconv"
+
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/internal/ast"
+)
+
+// The grouping functions take an array of objects and a path to a field in each object, for example "user" or
+// "metadata.namespace". The value of the field becomes a key of the resulting object, strings are used as they are,
+// numbers and booleans are converted to strings and a missing or nil field is grouped under "nil". Combine the result
+// with @keys, @values or @entries to iterate over the groups.
+
+// @group_by(arr, path) returns an object mapping each distinct value of path to the array of elements having that
+// value, in their original order.
+func _groupBy(args []interface{}) (interface{}, error) {
+	result := make(map[string]interface{})
+	err := eachGroupKey("group_by", args, func(key string, elt interface{}) {
+		group, _ := result[key].([]interface{})
+		result[key] = append(group, elt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// @count_by(arr, path) returns an object mapping each distinct value of path to the number of elements having that
+// value, for example @count_by($listeners, "user") returns {"root": 2, "www-data": 1}.
+func _countBy(args []interface{}) (interface{}, error) {
+	result := make(map[string]interface{})
+	err := eachGroupKey("count_by", args, func(key string, elt interface{}) {
+		count, _ := result[key].(float64)
+		result[key] = count + 1
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// @index_by(arr, path) returns an object mapping each value of path to the element having that value. If several
+// elements have the same value the last one wins.
+func _indexBy(args []interface{}) (interface{}, error) {
+	result := make(map[string]interface{})
+	err := eachGroupKey("index_by", args, func(key string, elt interface{}) {
+		result[key] = elt
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// eachGroupKey checks the arguments of the grouping functions and calls fn with the group key of every element.
+func eachGroupKey(name string, args []interface{}, fn func(key string, elt interface{})) error {
+	if err := checkArgs(name, args, 2); err != nil {
+		return err
+	}
+	arr, err := arrayArg(name, args, 0)
+	if err != nil {
+		return err
+	}
+	path, err := stringArg(name, args, 1)
+	if err != nil {
+		return err
+	}
+	for _, elt := range arr {
+		val, err := lookupPath(elt, path)
+		if err != nil {
+			return err
+		}
+		key, err := groupKey(val)
+		if err != nil {
+			return err
+		}
+		fn(key, elt)
+	}
+	return nil
+}
+
+// groupKey converts a scalar value to the string used to key it in an object. Numbers are keyed by value whatever
+// their Go representation.
+func groupKey(v interface{}) (string, error) {
+	v = ast.Normalize(v)
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case nil:
+		return "nil", nil
+	}
+	name, err := typeName(v)
+	if err != nil {
+		return "", err
+	}
+	return "", errors.New(errors.InvalidArgument, "can't group by %s value, expected a string, number, bool or nil", name)
+}
@@ -0,0 +1,141 @@
+package context
+
+import (
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/internal/ast"
+)
+
+// @join(left, right, lpath, rpath, mode) correlates two arrays of objects, pairing every element of left with every
+// element of right where the field lpath refers to in the left element equals the field rpath refers to in the right
+// element. Each pair is merged into a single object holding the fields of both, if both have a field with the same
+// name the value from the left element is kept. For example @join($processes, $listening_ports, "pid", "pid") returns
+// the processes with a listening port along with the port details, which can then be filtered with @select. Elements
+// whose key is nil or missing never match. mode is optional, "inner" (the default) drops left elements without a
+// match, "left" keeps them unchanged.
+func _join(args []interface{}) (interface{}, error) {
+	if len(args) < 4 || len(args) > 5 {
+		return nil, errors.New(errors.SyntaxError, "wrong number of arguments for join, expected 4 or 5 got %d", len(args))
+	}
+	left, err := arrayArg("join", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	right, err := arrayArg("join", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	lpath, err := stringArg("join", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	rpath, err := stringArg("join", args, 3)
+	if err != nil {
+		return nil, err
+	}
+	keepUnmatched := false
+	if len(args) > 4 {
+		mode, err := stringArg("join", args, 4)
+		if err != nil {
+			return nil, err
+		}
+		switch mode {
+		case "inner":
+		case "left":
+			keepUnmatched = true
+		default:
+			return nil, errors.New(errors.InvalidArgument, "join mode must be \"inner\" or \"left\" got %q", mode)
+		}
+	}
+
+	// index the right hand side by key so each left element is matched without scanning right
+	index := make(map[string][]map[string]interface{})
+	for i := range right {
+		obj, err := objectArg("join", right, i)
+		if err != nil {
+			return nil, err
+		}
+		key, ok, err := joinKey(obj, rpath)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			index[key] = append(index[key], obj)
+		}
+	}
+
+	result := []interface{}{}
+	for i := range left {
+		obj, err := objectArg("join", left, i)
+		if err != nil {
+			return nil, err
+		}
+		key, ok, err := joinKey(obj, lpath)
+		if err != nil {
+			return nil, err
+		}
+		matched := false
+		if ok {
+			lval, _ := lookupPath(obj, lpath)
+			for _, candidate := range index[key] {
+				// keys of different types can share a group key, "1" and 1 for instance, so confirm the match
+				if rval, _ := lookupPath(candidate, rpath); !ast.Equal(lval, rval) {
+					continue
+				}
+				result = append(result, merge(obj, candidate))
+				matched = true
+			}
+		}
+		if !matched && keepUnmatched {
+			result = append(result, obj)
+		}
+	}
+	return result, nil
+}
+
+// @lookup(arr, path, value) returns the first element of arr where the field path refers to equals value, or nil if
+// there isn't one. @lookup($listening_ports, "port", 22) returns the details of the process listening on port 22.
+func _lookup(args []interface{}) (interface{}, error) {
+	if err := checkArgs("lookup", args, 3); err != nil {
+		return nil, err
+	}
+	arr, err := arrayArg("lookup", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	path, err := stringArg("lookup", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, elt := range arr {
+		val, err := lookupPath(elt, path)
+		if err != nil {
+			return nil, err
+		}
+		if ast.Equal(val, args[2]) {
+			return elt, nil
+		}
+	}
+	return nil, nil
+}
+
+// joinKey returns the group key of the field path refers to in obj, ok is false if the field is nil or missing.
+func joinKey(obj map[string]interface{}, path string) (key string, ok bool, err error) {
+	val, err := lookupPath(obj, path)
+	if err != nil || ast.Normalize(val) == nil {
+		return "", false, err
+	}
+	key, err = groupKey(val)
+	return key, err == nil, err
+}
+
+// merge returns a new object with the fields of left and right, left wins if both have the same field.
+func merge(left, right map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(left)+len(right))
+	for k, v := range right {
+		result[k] = v
+	}
+	for k, v := range left {
+		result[k] = v
+	}
+	return result
+}
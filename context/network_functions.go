@@ -0,0 +1,137 @@
+package context
+
+import (
+	"net/netip"
+
+	"github.com/murphybytes/analyze/errors"
+)
+
+// @ip_in_cidr(addr, cidr) returns true if the IPv4 or IPv6 address addr is contained in the network cidr, for
+// example @ip_in_cidr($addr, "10.0.0.0/8"). cidr may also be an array of networks in which case the result is true
+// if addr is contained in any of them.
+func _ipInCidr(args []interface{}) (interface{}, error) {
+	if err := checkArgs("ip_in_cidr", args, 2); err != nil {
+		return nil, err
+	}
+	addr, err := parseAddr("ip_in_cidr", args[0])
+	if err != nil {
+		return nil, err
+	}
+	var networks []interface{}
+	switch t := args[1].(type) {
+	case string:
+		networks = []interface{}{t}
+	case []interface{}:
+		networks = t
+	default:
+		return nil, errors.New(errors.TypeMismatch, "expected string or array got %T for argument 2 of ip_in_cidr", args[1])
+	}
+	for _, network := range networks {
+		prefix, err := parsePrefix("ip_in_cidr", network)
+		if err != nil {
+			return nil, err
+		}
+		if prefix.Contains(addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// @is_private(addr) returns true if addr is in a private address range as defined by RFC 1918 for IPv4 and
+// RFC 4193 for IPv6.
+func _isPrivate(args []interface{}) (interface{}, error) {
+	if err := checkArgs("is_private", args, 1); err != nil {
+		return nil, err
+	}
+	addr, err := parseAddr("is_private", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return addr.IsPrivate(), nil
+}
+
+// @is_loopback(addr) returns true if addr is a loopback address such as 127.0.0.1 or ::1.
+func _isLoopback(args []interface{}) (interface{}, error) {
+	if err := checkArgs("is_loopback", args, 1); err != nil {
+		return nil, err
+	}
+	addr, err := parseAddr("is_loopback", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return addr.IsLoopback(), nil
+}
+
+// @is_ip(str) returns true if str is a valid IPv4 or IPv6 address. Unlike the other network functions a malformed
+// address is not an error.
+func _isIP(args []interface{}) (interface{}, error) {
+	if err := checkArgs("is_ip", args, 1); err != nil {
+		return nil, err
+	}
+	s, err := stringArg("is_ip", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, err = netip.ParseAddr(s)
+	return err == nil, nil
+}
+
+// @ip_version(addr) returns 4 for IPv4 addresses and 6 for IPv6 addresses. IPv4 addresses mapped into IPv6 such as
+// ::ffff:10.0.0.1 are reported as 4.
+func _ipVersion(args []interface{}) (interface{}, error) {
+	if err := checkArgs("ip_version", args, 1); err != nil {
+		return nil, err
+	}
+	addr, err := parseAddr("ip_version", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if addr.Is4() {
+		return 4, nil
+	}
+	return 6, nil
+}
+
+// @cidr_overlap(cidr1, cidr2) returns true if the two networks share any addresses.
+func _cidrOverlap(args []interface{}) (interface{}, error) {
+	if err := checkArgs("cidr_overlap", args, 2); err != nil {
+		return nil, err
+	}
+	l, err := parsePrefix("cidr_overlap", args[0])
+	if err != nil {
+		return nil, err
+	}
+	r, err := parsePrefix("cidr_overlap", args[1])
+	if err != nil {
+		return nil, err
+	}
+	return l.Overlaps(r), nil
+}
+
+// parseAddr parses v as an IP address. Zones are dropped and IPv4 mapped IPv6 addresses are unmapped so they
+// compare equal to their IPv4 form.
+func parseAddr(name string, v interface{}) (netip.Addr, error) {
+	s, ok := v.(string)
+	if !ok {
+		return netip.Addr{}, errors.New(errors.TypeMismatch, "expected address string got %T for %s", v, name)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, errors.New(errors.InvalidArgument, "malformed address %q for %s", s, name)
+	}
+	return addr.WithZone("").Unmap(), nil
+}
+
+// parsePrefix parses v as a network in CIDR notation.
+func parsePrefix(name string, v interface{}) (netip.Prefix, error) {
+	s, ok := v.(string)
+	if !ok {
+		return netip.Prefix{}, errors.New(errors.TypeMismatch, "expected network string got %T for %s", v, name)
+	}
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, errors.New(errors.InvalidArgument, "malformed network %q for %s", s, name)
+	}
+	return prefix.Masked(), nil
+}
@@ -0,0 +1,123 @@
+package context
+
+import (
+	"sort"
+
+	"github.com/murphybytes/analyze/errors"
+)
+
+// @keys(obj) returns the keys of obj as a sorted array of strings.
+func _keys(args []interface{}) (interface{}, error) {
+	if err := checkArgs("keys", args, 1); err != nil {
+		return nil, err
+	}
+	obj, err := objectArg("keys", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(obj))
+	for _, key := range sortedKeys(obj) {
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+// @values(obj) returns the values of obj as an array ordered by key.
+func _values(args []interface{}) (interface{}, error) {
+	if err := checkArgs("values", args, 1); err != nil {
+		return nil, err
+	}
+	obj, err := objectArg("values", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(obj))
+	for _, key := range sortedKeys(obj) {
+		result = append(result, obj[key])
+	}
+	return result, nil
+}
+
+// @entries(obj) returns an array of objects with key and value fields, one for each field of obj ordered by key.
+// It is useful to filter the fields of an object with @select, for example
+// @select(@entries($annotations), "$value == \"true\"") returns the annotations that are set to "true".
+func _entries(args []interface{}) (interface{}, error) {
+	if err := checkArgs("entries", args, 1); err != nil {
+		return nil, err
+	}
+	obj, err := objectArg("entries", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, 0, len(obj))
+	for _, key := range sortedKeys(obj) {
+		result = append(result, map[string]interface{}{
+			"key":   key,
+			"value": obj[key],
+		})
+	}
+	return result, nil
+}
+
+// @type(val) returns the type of val, one of "number", "string", "bool", "nil", "array" or "object". A regular
+// expression literal is a "string".
+func _type(args []interface{}) (interface{}, error) {
+	if err := checkArgs("type", args, 1); err != nil {
+		return nil, err
+	}
+	return typeName(args[0])
+}
+
+// @is_array(val) returns true if val is an array.
+func _isArray(args []interface{}) (interface{}, error) {
+	if err := checkArgs("is_array", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].([]interface{})
+	return ok, nil
+}
+
+// @is_object(val) returns true if val is an object.
+func _isObject(args []interface{}) (interface{}, error) {
+	if err := checkArgs("is_object", args, 1); err != nil {
+		return nil, err
+	}
+	_, ok := args[0].(map[string]interface{})
+	return ok, nil
+}
+
+func typeName(v interface{}) (string, error) {
+	switch v.(type) {
+	case float64, int:
+		return "number", nil
+	case string:
+		return "string", nil
+	case bool:
+		return "bool", nil
+	case nil:
+		return "nil", nil
+	case []interface{}:
+		return "array", nil
+	case map[string]interface{}:
+		return "object", nil
+	}
+	return "", errors.UnsupportedTypeError(v)
+}
+
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// objectArg returns the argument at index i as an object or a type mismatch error.
+func objectArg(name string, args []interface{}, i int) (map[string]interface{}, error) {
+	obj, ok := args[i].(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.TypeMismatch, "expected object got %T for argument %d of %s", args[i], i+1, name)
+	}
+	return obj, nil
+}
@@ -0,0 +1,66 @@
+package context
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+)
+
+// DefaultRegexpCacheSize is the number of compiled regular expressions a context keeps if RegexpCacheSize is not
+// used.
+const DefaultRegexpCacheSize = 128
+
+// regexpCache is a concurrency safe, least recently used cache of compiled regular expressions. It is used for
+// patterns that are only known when an expression is evaluated, for instance patterns read from context data, regular
+// expression literals are compiled when an expression is parsed and don't need it.
+type regexpCache struct {
+	mut      sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type regexpCacheEntry struct {
+	pattern string
+	regexp  *regexp.Regexp
+}
+
+func newRegexpCache(capacity int) *regexpCache {
+	return &regexpCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// compile returns the compiled pattern from the cache, compiling and caching it if it isn't present.
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mut.Lock()
+	if elt, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(elt)
+		c.mut.Unlock()
+		return elt.Value.(*regexpCacheEntry).regexp, nil
+	}
+	c.mut.Unlock()
+
+	// compile without holding the lock, if another goroutine compiles the same pattern concurrently the last one
+	// stored wins which is harmless
+	re, err := regexp.Compile(pattern)
+	if err != nil || c.capacity == 0 {
+		return re, err
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if elt, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(elt)
+		return elt.Value.(*regexpCacheEntry).regexp, nil
+	}
+	c.entries[pattern] = c.order.PushFront(&regexpCacheEntry{pattern: pattern, regexp: re})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*regexpCacheEntry).pattern)
+	}
+	return re, nil
+}
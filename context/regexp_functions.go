@@ -0,0 +1,97 @@
+package context
+
+// @capture(str, regex) returns the groups captured by the first match of regex in str. If regex has named groups
+// the result is an object keyed by group name, @capture("nginx:1.21", /:(?P<tag>[\w.-]+)$/) returns {"tag": "1.21"},
+// otherwise it is an array of the captured groups in order. Groups that don't participate in the match are nil. If
+// regex doesn't match str the result is nil.
+func (c *Context) _capture(args []interface{}) (interface{}, error) {
+	if err := checkArgs("capture", args, 2); err != nil {
+		return nil, err
+	}
+	s, err := stringArg("capture", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	regex, err := c.regexpArg("capture", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	m := regex.FindStringSubmatchIndex(s)
+	if m == nil {
+		return nil, nil
+	}
+	group := func(i int) interface{} {
+		if m[2*i] < 0 {
+			return nil
+		}
+		return s[m[2*i]:m[2*i+1]]
+	}
+	names := regex.SubexpNames()
+	named := false
+	for _, name := range names {
+		if name != "" {
+			named = true
+			break
+		}
+	}
+	if named {
+		result := make(map[string]interface{})
+		for i, name := range names {
+			if i > 0 && name != "" {
+				result[name] = group(i)
+			}
+		}
+		return result, nil
+	}
+	result := make([]interface{}, 0, len(names)-1)
+	for i := 1; i < len(names); i++ {
+		result = append(result, group(i))
+	}
+	return result, nil
+}
+
+// @find_all(str, regex) returns an array of every non overlapping match of regex in str, if regex has a capture
+// group the first group of each match is returned instead of the whole match.
+func (c *Context) _findAll(args []interface{}) (interface{}, error) {
+	if err := checkArgs("find_all", args, 2); err != nil {
+		return nil, err
+	}
+	s, err := stringArg("find_all", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	regex, err := c.regexpArg("find_all", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, m := range regex.FindAllStringSubmatch(s, -1) {
+		if len(m) > 1 {
+			result = append(result, m[1])
+			continue
+		}
+		result = append(result, m[0])
+	}
+	return result, nil
+}
+
+// @replace_re(str, regex, replacement) replaces every match of regex in str with replacement. Inside replacement $1
+// or ${name} refer to the text of the numbered or named capture group.
+func (c *Context) _replaceRe(args []interface{}) (interface{}, error) {
+	if err := checkArgs("replace_re", args, 3); err != nil {
+		return nil, err
+	}
+	s, err := stringArg("replace_re", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	regex, err := c.regexpArg("replace_re", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := stringArg("replace_re", args, 2)
+	if err != nil {
+		return nil, err
+	}
+	return regex.ReplaceAllString(s, replacement), nil
+}
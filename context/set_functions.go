@@ -0,0 +1,154 @@
+package context
+
+import (
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/internal/ast"
+)
+
+// The set functions treat arrays as sets. Elements are compared by value as described by ast.Equal so numbers,
+// strings, booleans, nil, arrays and objects can all be set members. Results never contain duplicates and keep the
+// order in which elements were first seen.
+
+// @union(arr1, arr2, ... arrN) returns the elements that are in any of the arrays.
+func _union(args []interface{}) (interface{}, error) {
+	return union("union", args)
+}
+
+// union implements @union, name is the function reported in errors.
+func union(name string, args []interface{}) (interface{}, error) {
+	arrs, err := arrayArgs(name, args)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, arr := range arrs {
+		for _, elt := range arr {
+			if !contains(result, elt) {
+				result = append(result, elt)
+			}
+		}
+	}
+	return result, nil
+}
+
+// @intersect(arr1, arr2, ... arrN) returns the elements of arr1 that are in all of the other arrays.
+func _intersect(args []interface{}) (interface{}, error) {
+	arrs, err := arrayArgs("intersect", args)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, elt := range arrs[0] {
+		if contains(result, elt) {
+			continue
+		}
+		found := true
+		for _, arr := range arrs[1:] {
+			if !contains(arr, elt) {
+				found = false
+				break
+			}
+		}
+		if found {
+			result = append(result, elt)
+		}
+	}
+	return result, nil
+}
+
+// @difference(arr1, arr2, ... arrN) returns the elements of arr1 that are not in any of the other arrays, for example
+// @difference($open_ports, $allowed_ports) returns the open ports that are not in an allow list.
+func _difference(args []interface{}) (interface{}, error) {
+	arrs, err := arrayArgs("difference", args)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, elt := range arrs[0] {
+		if contains(result, elt) {
+			continue
+		}
+		found := false
+		for _, arr := range arrs[1:] {
+			if contains(arr, elt) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, elt)
+		}
+	}
+	return result, nil
+}
+
+// @unique(arr) returns arr with duplicate elements removed.
+func _unique(args []interface{}) (interface{}, error) {
+	return unique("unique", args)
+}
+
+// unique implements @unique and its alias @distinct, name is the function reported in errors.
+func unique(name string, args []interface{}) (interface{}, error) {
+	if err := checkArgs(name, args, 1); err != nil {
+		return nil, err
+	}
+	return union(name, args)
+}
+
+// @subset(arr1, arr2) returns true if every element of arr1 is in arr2.
+func _subset(args []interface{}) (interface{}, error) {
+	if err := checkArgs("subset", args, 2); err != nil {
+		return nil, err
+	}
+	arrs, err := arrayArgs("subset", args)
+	if err != nil {
+		return nil, err
+	}
+	return isSubset(arrs[0], arrs[1]), nil
+}
+
+// @superset(arr1, arr2) returns true if every element of arr2 is in arr1.
+func _superset(args []interface{}) (interface{}, error) {
+	if err := checkArgs("superset", args, 2); err != nil {
+		return nil, err
+	}
+	arrs, err := arrayArgs("superset", args)
+	if err != nil {
+		return nil, err
+	}
+	return isSubset(arrs[1], arrs[0]), nil
+}
+
+func isSubset(sub, super []interface{}) bool {
+	for _, elt := range sub {
+		if !contains(super, elt) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(arr []interface{}, val interface{}) bool {
+	for _, elt := range arr {
+		if ast.Equal(elt, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// arrayArgs checks that a function was called with one or more arguments that are all arrays.
+func arrayArgs(name string, args []interface{}) ([][]interface{}, error) {
+	if len(args) == 0 {
+		return nil, errors.New(errors.SyntaxError, "%s expects at least one argument", name)
+	}
+	arrs := make([][]interface{}, 0, len(args))
+	for i := range args {
+		arr, err := arrayArg(name, args, i)
+		if err != nil {
+			return nil, err
+		}
+		arrs = append(arrs, arr)
+	}
+	return arrs, nil
+}
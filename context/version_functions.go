@@ -0,0 +1,353 @@
+package context
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/murphybytes/analyze/errors"
+)
+
+// @semver_compare(v1, v2) compares two semantic versions returning -1 if v1 < v2, 0 if they are equal and 1 if
+// v1 > v2. Versions may have a leading v and omit the minor and patch numbers, so v1.2 is the same as 1.2.0.
+// Pre-release versions are ordered before the release they precede and build metadata is ignored.
+func _semverCompare(args []interface{}) (interface{}, error) {
+	if err := checkArgs("semver_compare", args, 2); err != nil {
+		return nil, err
+	}
+	l, err := semverArg("semver_compare", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	r, err := semverArg("semver_compare", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	return l.compare(r), nil
+}
+
+// @semver_satisfies(version, constraint) returns true if version satisfies constraint. A constraint is a space
+// separated list of comparisons that must all hold, for example ">=1.2.0 <2.0.0". Supported operators are =, !=,
+// <, <=, >, >=, ~ (patch updates allowed) and ^ (updates that don't change the leftmost non zero number). Alternative
+// constraints can be joined with ||.
+func _semverSatisfies(args []interface{}) (interface{}, error) {
+	if err := checkArgs("semver_satisfies", args, 2); err != nil {
+		return nil, err
+	}
+	v, err := semverArg("semver_satisfies", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	constraint, err := stringArg("semver_satisfies", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, alternative := range strings.Split(constraint, "||") {
+		ok, err := satisfiesAll(v, alternative)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// @version_compare(v1, v2) compares package versions that don't follow semantic versioning such as those used by
+// Debian and RPM, for example "1:2.30-1ubuntu0.1" or "7.61.1-18.el8_4.1". It returns -1, 0 or 1 like semver_compare.
+// Versions are compared using the dpkg algorithm: epoch, then upstream version, then revision, where runs of digits
+// compare numerically, letters sort before other characters and ~ sorts before anything, even the end of the string.
+func _versionCompare(args []interface{}) (interface{}, error) {
+	if err := checkArgs("version_compare", args, 2); err != nil {
+		return nil, err
+	}
+	l, err := stringArg("version_compare", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	r, err := stringArg("version_compare", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	lv, err := parsePackageVersion(l)
+	if err != nil {
+		return nil, err
+	}
+	rv, err := parsePackageVersion(r)
+	if err != nil {
+		return nil, err
+	}
+	return lv.compare(rv), nil
+}
+
+type semver struct {
+	numbers [3]uint64
+	// parts is the number of numeric parts that were actually specified, used to expand ~ and ^ constraints.
+	parts      int
+	prerelease []string
+}
+
+func semverArg(name string, args []interface{}, i int) (semver, error) {
+	s, err := stringArg(name, args, i)
+	if err != nil {
+		return semver{}, err
+	}
+	return parseSemver(s)
+}
+
+func parseSemver(s string) (semver, error) {
+	var v semver
+	str := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "v"), "V")
+	if i := strings.Index(str, "+"); i >= 0 {
+		str = str[:i]
+	}
+	if i := strings.Index(str, "-"); i >= 0 {
+		v.prerelease = strings.Split(str[i+1:], ".")
+		str = str[:i]
+		for _, id := range v.prerelease {
+			if id == "" {
+				return semver{}, errors.New(errors.InvalidArgument, "malformed pre-release in version %q", s)
+			}
+		}
+	}
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 {
+		return semver{}, errors.New(errors.InvalidArgument, "malformed semantic version %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return semver{}, errors.New(errors.InvalidArgument, "malformed semantic version %q", s)
+		}
+		v.numbers[i] = n
+	}
+	v.parts = len(parts)
+	return v, nil
+}
+
+func (v semver) compare(o semver) int {
+	for i := range v.numbers {
+		if v.numbers[i] != o.numbers[i] {
+			return compareUint(v.numbers[i], o.numbers[i])
+		}
+	}
+	// a version without a pre-release has higher precedence than one with
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		if c := comparePrereleaseID(v.prerelease[i], o.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareUint(uint64(len(v.prerelease)), uint64(len(o.prerelease)))
+}
+
+// numeric identifiers compare numerically and have lower precedence than alphanumeric identifiers which compare
+// lexically
+func comparePrereleaseID(l, r string) int {
+	ln, lerr := strconv.ParseUint(l, 10, 64)
+	rn, rerr := strconv.ParseUint(r, 10, 64)
+	switch {
+	case lerr == nil && rerr == nil:
+		return compareUint(ln, rn)
+	case lerr == nil:
+		return -1
+	case rerr == nil:
+		return 1
+	}
+	return strings.Compare(l, r)
+}
+
+func compareUint(l, r uint64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+var semverOperators = []string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"}
+
+// satisfiesAll returns true if v satisfies every comparison in a space separated constraint.
+func satisfiesAll(v semver, constraint string) (bool, error) {
+	fields := strings.Fields(strings.ReplaceAll(constraint, ",", " "))
+	if len(fields) == 0 {
+		return false, errors.New(errors.InvalidArgument, "empty version constraint")
+	}
+	for i := 0; i < len(fields); i++ {
+		var op string
+		for _, candidate := range semverOperators {
+			if strings.HasPrefix(fields[i], candidate) {
+				op = candidate
+				break
+			}
+		}
+		operand := strings.TrimPrefix(fields[i], op)
+		// allow whitespace between the operator and version as in ">= 1.2.0"
+		if operand == "" && i+1 < len(fields) {
+			i++
+			operand = fields[i]
+		}
+		target, err := parseSemver(operand)
+		if err != nil {
+			return false, err
+		}
+		if !satisfies(v, op, target) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func satisfies(v semver, op string, target semver) bool {
+	c := v.compare(target)
+	switch op {
+	case "", "=", "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "~":
+		// ~1.2.3 allows >=1.2.3 <1.3.0, ~1 allows >=1.0.0 <2.0.0
+		var upper semver
+		if target.parts == 1 {
+			upper.numbers = [3]uint64{target.numbers[0] + 1, 0, 0}
+		} else {
+			upper.numbers = [3]uint64{target.numbers[0], target.numbers[1] + 1, 0}
+		}
+		return c >= 0 && v.compare(upper) < 0
+	case "^":
+		// ^1.2.3 allows >=1.2.3 <2.0.0, ^0.2.3 allows >=0.2.3 <0.3.0 and ^0.0.3 allows >=0.0.3 <0.0.4
+		var upper semver
+		switch {
+		case target.numbers[0] > 0 || target.parts == 1:
+			upper.numbers = [3]uint64{target.numbers[0] + 1, 0, 0}
+		case target.numbers[1] > 0 || target.parts == 2:
+			upper.numbers = [3]uint64{0, target.numbers[1] + 1, 0}
+		default:
+			upper.numbers = [3]uint64{0, 0, target.numbers[2] + 1}
+		}
+		return c >= 0 && v.compare(upper) < 0
+	}
+	return false
+}
+
+type packageVersion struct {
+	epoch    uint64
+	upstream string
+	revision string
+}
+
+// parsePackageVersion splits a version of the form [epoch:]upstream[-revision].
+func parsePackageVersion(s string) (packageVersion, error) {
+	var v packageVersion
+	str := strings.TrimSpace(s)
+	if str == "" {
+		return v, errors.New(errors.InvalidArgument, "empty package version")
+	}
+	if i := strings.Index(str, ":"); i >= 0 {
+		epoch, err := strconv.ParseUint(str[:i], 10, 64)
+		if err != nil {
+			return v, errors.New(errors.InvalidArgument, "malformed epoch in package version %q", s)
+		}
+		v.epoch = epoch
+		str = str[i+1:]
+	}
+	if i := strings.LastIndex(str, "-"); i >= 0 {
+		v.revision = str[i+1:]
+		str = str[:i]
+	}
+	if str == "" {
+		return v, errors.New(errors.InvalidArgument, "malformed package version %q", s)
+	}
+	v.upstream = str
+	return v, nil
+}
+
+func (v packageVersion) compare(o packageVersion) int {
+	if v.epoch != o.epoch {
+		return compareUint(v.epoch, o.epoch)
+	}
+	if c := compareVersionString(v.upstream, o.upstream); c != 0 {
+		return c
+	}
+	return compareVersionString(v.revision, o.revision)
+}
+
+// compareVersionString compares alternating runs of non digits and digits as dpkg does.
+func compareVersionString(l, r string) int {
+	for len(l) > 0 || len(r) > 0 {
+		for (len(l) > 0 && !isDigit(l[0])) || (len(r) > 0 && !isDigit(r[0])) {
+			lc, rc := versionCharOrder(l), versionCharOrder(r)
+			if lc != rc {
+				return sign(lc - rc)
+			}
+			l, r = l[1:], r[1:]
+		}
+		l, r = strings.TrimLeft(l, "0"), strings.TrimLeft(r, "0")
+		firstDiff := 0
+		for len(l) > 0 && len(r) > 0 && isDigit(l[0]) && isDigit(r[0]) {
+			if firstDiff == 0 {
+				firstDiff = int(l[0]) - int(r[0])
+			}
+			l, r = l[1:], r[1:]
+		}
+		if len(l) > 0 && isDigit(l[0]) {
+			return 1
+		}
+		if len(r) > 0 && isDigit(r[0]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return 0
+}
+
+// versionCharOrder returns the sort weight of the first character of s. Digits and the end of the string weigh 0,
+// letters sort before other characters and ~ sorts before everything.
+func versionCharOrder(s string) int {
+	if len(s) == 0 {
+		return 0
+	}
+	c := s[0]
+	switch {
+	case isDigit(c):
+		return 0
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	}
+	return int(c) + 256
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
@@ -16,6 +16,7 @@ const (
 	DuplicateFunction
 	InvalidFunction
 	InvalidArgumentType
+	InvalidArgument
 )
 
 type Error interface {
@@ -2,6 +2,7 @@ package expression
 
 import (
 	"github.com/murphybytes/analyze/context"
+	"github.com/murphybytes/analyze/errors"
 	"github.com/murphybytes/analyze/internal/ast"
 	"sync"
 )
@@ -22,7 +23,7 @@ func Evaluate(data interface{}, expression string)(bool,error) {
 	if err != nil {
 		return false, err
 	}
-	return bool(*result.Bool), nil
+	return toBool(result)
 }
 
 // EvaluateContext takes context with data and optional user defined functions and
@@ -38,6 +39,29 @@ func EvaluateContext(ctx ast.Context, expression string) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	return toBool(result)
+}
+
+// EvaluateValue evaluates an expression that doesn't have to be a predicate and returns its result, for example
+// @keys($labels) returns an array of strings. Numbers are returned as float64, objects as map[string]interface{} and
+// arrays as []interface{}.
+func EvaluateValue(ctx ast.Context, expression string) (interface{}, error) {
+	var t ast.Expression
+	if err := ast.Parser().ParseString("", expression, &t); err != nil {
+		return nil, err
+	}
+	result, err := t.Eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result.Interface()
+}
+
+// toBool returns the result of a predicate, it is an error for a predicate to evaluate to anything but a bool.
+func toBool(result *ast.Value) (bool, error) {
+	if result.Bool == nil {
+		return false, errors.New(errors.TypeMismatch, "expression must evaluate to a bool")
+	}
 	return bool(*result.Bool), nil
 }
 
@@ -56,10 +80,21 @@ func(p *PreparedExpression) Evaluate(ctx ast.Context)(bool, error){
 	if  err != nil {
 		return false, err
 	}
-	return bool(*result.Bool), nil
+	return toBool(result)
 
 }
 
+// EvaluateValue evaluates a prepared expression that doesn't have to be a predicate and returns its result.
+func (p *PreparedExpression) EvaluateValue(ctx ast.Context) (interface{}, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	result, err := p.tree.Eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return result.Interface()
+}
+
 // Prepare create an expression that you can use repeatedly with different input data.
 func Prepare(expression string)(*PreparedExpression,error){
 	parser := ast.Parser()
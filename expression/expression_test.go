@@ -3,9 +3,12 @@ package expression
 import (
 	"fmt"
 	"github.com/murphybytes/analyze/internal/ast"
+	"strings"
 	"testing"
 
 	"github.com/murphybytes/analyze/context"
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/format"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 )
@@ -121,7 +124,7 @@ func TestEval(t *testing.T) {
 		},
 		{
 			name:       "greater than or equal to",
-			expression: `3 >= 4 && 3 >= 3`,
+			expression: `4 >= 3 && 3 >= 3 && !(3 >= 4)`,
 			expected:   true,
 		},
 		{
@@ -216,44 +219,1047 @@ func TestEval(t *testing.T) {
 			data:       []interface{}{3, 1, 2, 3, 3},
 			expected:   true,
 		},
+		{
+			name:       "empty select is an empty array",
+			expression: `@len(@select($x, "$ > 5")) == 0 && @select($x, "$ > 5") == [] && @select($x, "$ > 5") != nil`,
+			data:       map[string]interface{}{"x": []interface{}{1, 2}},
+			expected:   true,
+		},
+		{
+			name:       "nil collections in data are nil",
+			expression: `$x == nil && $m == nil`,
+			data:       map[string]interface{}{"x": []interface{}(nil), "m": map[string]interface{}(nil)},
+			expected:   true,
+		},
 		{
 			name:       "in func test",
 			expression: `@in( @array(1, 2, 3), 2)`,
 			expected:   true,
 		},
 		{
-			name:       "in with string arr var",
-			expression: `@in( $arr, "foo")`,
-			data: []interface{}{
-				"zip",
-				"foo",
-				"bazz",
+			name:       "in with string arr var",
+			expression: `@in( $arr, "foo")`,
+			data: []interface{}{
+				"zip",
+				"foo",
+				"bazz",
+			},
+			expected: true,
+		},
+		{
+			name:       "has function",
+			expression: `@has($bar, "foo") && $bar.foo == 3`,
+			data: map[string]interface{}{
+				"bar": map[string]interface{}{
+					"foo": 3,
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "root object dotted reference",
+			expression: `3 < $foo`,
+			data: map[string]interface{}{
+				"foo": 4,
+			},
+			expected: true,
+		},
+		{
+			name: "match",
+			expression: `@match("10.10.10.10", /^([0-9]{1,3}\.){3}[0-9]{1,3}$/)`,
+			expected: true,
+		},
+		{
+			name:       "ip in cidr",
+			expression: `@ip_in_cidr($addr, "10.0.0.0/8") && !@ip_in_cidr($addr, "192.168.0.0/16")`,
+			data:       map[string]interface{}{"addr": "10.1.2.3"},
+			expected:   true,
+		},
+		{
+			name:       "ip in any cidr",
+			expression: `@ip_in_cidr("fd00::1", @array("10.0.0.0/8", "fd00::/8"))`,
+			expected:   true,
+		},
+		{
+			name:       "mapped ipv4 in cidr",
+			expression: `@ip_in_cidr("::ffff:10.0.0.1", "10.0.0.0/8") && @ip_version("::ffff:10.0.0.1") == 4`,
+			expected:   true,
+		},
+		{
+			name:       "is private",
+			expression: `@is_private("172.16.5.4") && !@is_private("8.8.8.8") && @is_private("fd12::1")`,
+			expected:   true,
+		},
+		{
+			name:       "is loopback",
+			expression: `@is_loopback("127.0.0.1") && @is_loopback("::1")`,
+			expected:   true,
+		},
+		{
+			name:       "is ip",
+			expression: `@is_ip("2001:db8::1") && !@is_ip("10.0.0.256")`,
+			expected:   true,
+		},
+		{
+			name:       "ip version",
+			expression: `@ip_version("2001:db8::1") == 6 && @ip_version("10.0.0.1") == 4`,
+			expected:   true,
+		},
+		{
+			name:       "cidr overlap",
+			expression: `@cidr_overlap("10.0.0.0/8", "10.20.0.0/16") && !@cidr_overlap("10.0.0.0/16", "10.1.0.0/16")`,
+			expected:   true,
+		},
+		{
+			name:       "malformed address",
+			expression: `@ip_in_cidr("10.0.0.256", "10.0.0.0/8")`,
+			wantErr:    true,
+		},
+		{
+			name:       "malformed network",
+			expression: `@cidr_overlap("10.0.0.0/33", "10.0.0.0/8")`,
+			wantErr:    true,
+		},
+		{
+			name:       "semver compare",
+			expression: `@semver_compare("1.10.0", "1.9.0") == 1 && @semver_compare("v1.2", "1.2.0") == 0`,
+			expected:   true,
+		},
+		{
+			name:       "semver compare pre-release",
+			expression: `@semver_compare("1.0.0-alpha.1", "1.0.0-alpha.beta") < 0 && @semver_compare("1.0.0-rc.1", "1.0.0") < 0`,
+			expected:   true,
+		},
+		{
+			name:       "semver satisfies range",
+			expression: `@semver_satisfies($version, ">=1.2.0 <2.0.0") && !@semver_satisfies($version, ">= 1.2.11")`,
+			data:       map[string]interface{}{"version": "1.2.10"},
+			expected:   true,
+		},
+		{
+			name:       "semver satisfies tilde and caret",
+			expression: `@semver_satisfies("1.2.9", "~1.2.3") && !@semver_satisfies("1.3.0", "~1.2.3") && @semver_satisfies("0.2.5", "^0.2.3") && !@semver_satisfies("0.3.0", "^0.2.3")`,
+			expected:   true,
+		},
+		{
+			name:       "semver satisfies alternatives",
+			expression: `@semver_satisfies("3.1.0", "<2.0.0 || >=3.0.0")`,
+			expected:   true,
+		},
+		{
+			name:       "malformed semver",
+			expression: `@semver_compare("1.2.x", "1.2.0") == 0`,
+			wantErr:    true,
+		},
+		{
+			name:       "package version compare",
+			expression: `@version_compare("1:2.30-1ubuntu0.1", "2.31-1") == 1 && @version_compare("1.0~rc1-1", "1.0-1") == -1 && @version_compare("7.61.1-22.el8", "7.61.1-18.el8_4.1") == 1`,
+			expected:   true,
+		},
+		{
+			name:       "union",
+			expression: `@len(@union(@array(1, 2), @array(2, 3), $more)) == 4`,
+			data:       map[string]interface{}{"more": []interface{}{3, 4}},
+			expected:   true,
+		},
+		{
+			name:       "intersect",
+			expression: `@len(@intersect($ports, @array(22, 443))) == 1`,
+			data:       map[string]interface{}{"ports": []interface{}{80, 443, 8080}},
+			expected:   true,
+		},
+		{
+			name:       "empty intersect",
+			expression: `@len(@intersect(@array("a"), @array("b"))) == 0`,
+			expected:   true,
+		},
+		{
+			name:       "difference",
+			expression: `@len(@difference($required, $labels)) == 1 && @in(@difference($required, $labels), "owner")`,
+			data: map[string]interface{}{
+				"required": []interface{}{"app", "owner", "app"},
+				"labels":   []interface{}{"app", "tier"},
+			},
+			expected: true,
+		},
+		{
+			name:       "unique objects",
+			expression: `@len(@unique($items)) == 2`,
+			data: map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"name": "nginx", "port": 80},
+					map[string]interface{}{"name": "nginx", "port": 80.0},
+					map[string]interface{}{"name": "redis", "port": 6379},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "unique mixed kinds",
+			expression: `@len(@unique(@array(1, "1", true, nil, 1, true))) == 4`,
+			expected:   true,
+		},
+		{
+			name:       "subset and superset",
+			expression: `@subset(@array(22), $ports) && @superset($ports, @array(22, 80)) && !@subset(@array(25), $ports)`,
+			data:       map[string]interface{}{"ports": []interface{}{22, 80}},
+			expected:   true,
+		},
+		{
+			name:       "set function type mismatch",
+			expression: `@len(@union(@array(1), 2)) == 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "array equality",
+			expression: `$spec.ports == $expected.ports && $spec.ports != @array(80)`,
+			data: map[string]interface{}{
+				"spec":     map[string]interface{}{"ports": []interface{}{80, 443}},
+				"expected": map[string]interface{}{"ports": []interface{}{80.0, 443.0}},
+			},
+			expected: true,
+		},
+		{
+			name:       "array order matters",
+			expression: `@array(1, 2) == @array(2, 1)`,
+			expected:   false,
+		},
+		{
+			name:       "object equality",
+			expression: `$a == $b && $a != $c`,
+			data: map[string]interface{}{
+				"a": map[string]interface{}{"name": "nginx", "ports": []interface{}{80}},
+				"b": map[string]interface{}{"name": "nginx", "ports": []interface{}{80}},
+				"c": map[string]interface{}{"name": "nginx", "ports": []interface{}{8080}},
+			},
+			expected: true,
+		},
+		{
+			name:       "array not equal to object",
+			expression: `$a != $b`,
+			data: map[string]interface{}{
+				"a": []interface{}{},
+				"b": map[string]interface{}{},
+			},
+			expected: true,
+		},
+		{
+			name:       "array compared to scalar",
+			expression: `@array(1) == 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "in finds object",
+			expression: `@in($containers, $wanted)`,
+			data: map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "sidecar"},
+					map[string]interface{}{"name": "nginx", "port": 80},
+				},
+				"wanted": map[string]interface{}{"name": "nginx", "port": 80},
+			},
+			expected: true,
+		},
+		{
+			name:       "in compares numbers by value",
+			expression: `@in($ports, 443) && !@in($ports, "443")`,
+			data:       map[string]interface{}{"ports": []interface{}{80, 443}},
+			expected:   true,
+		},
+		{
+			name:       "array literal",
+			expression: `@len([1, 2, "x"]) == 3 && @in([80, 443], $port)`,
+			data:       map[string]interface{}{"port": 443},
+			expected:   true,
+		},
+		{
+			name:       "empty array literal",
+			expression: `@len([]) == 0 && $ports != []`,
+			data:       map[string]interface{}{"ports": []interface{}{22}},
+			expected:   true,
+		},
+		{
+			name:       "array literal of variables",
+			expression: `[$a, $b[0]] == [1, 2]`,
+			data: map[string]interface{}{
+				"a": 1,
+				"b": []interface{}{2},
+			},
+			expected: true,
+		},
+		{
+			name:       "object literal",
+			expression: `$container == {"name": "nginx", "port": 80, "args": ["-g", "daemon off;"]}`,
+			data: map[string]interface{}{
+				"container": map[string]interface{}{
+					"name": "nginx",
+					"port": 80,
+					"args": []interface{}{"-g", "daemon off;"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "object literal in array",
+			expression: `@in($containers, {"name": "nginx"}) && @has({"a": nil}, "a") && {} != {"a": 1}`,
+			data: map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{"name": "nginx"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "object literal duplicate key",
+			expression: `{"a": 1, "a": 2} == {"a": 2}`,
+			wantErr:    true,
+		},
+		{
+			name:       "in operator array",
+			expression: `$x in ["a", "b"] && 3 in $nums && !(4 in $nums)`,
+			data: map[string]interface{}{
+				"x":    "b",
+				"nums": []interface{}{1, 2, 3},
+			},
+			expected: true,
+		},
+		{
+			name:       "not in operator",
+			expression: `$port not in [22, 23] && {"name": "nginx"} not in $containers`,
+			data: map[string]interface{}{
+				"port":       443,
+				"containers": []interface{}{map[string]interface{}{"name": "redis"}},
+			},
+			expected: true,
+		},
+		{
+			name:       "in operator object keys",
+			expression: `"app" in $labels && "owner" not in $labels`,
+			data: map[string]interface{}{
+				"labels": map[string]interface{}{"app": "web"},
+			},
+			expected: true,
+		},
+		{
+			name:       "in operator substring",
+			expression: `"corp" in $image && "latest" not in $image`,
+			data:       map[string]interface{}{"image": "registry.corp/nginx:1.21"},
+			expected:   true,
+		},
+		{
+			name:       "in operator type mismatch",
+			expression: `1 in "123"`,
+			wantErr:    true,
+		},
+		{
+			name:       "match operator",
+			expression: `$addr =~ /^([0-9]{1,3}\.){3}[0-9]{1,3}$/ && $addr !~ /^192\./`,
+			data:       map[string]interface{}{"addr": "10.10.10.10"},
+			expected:   true,
+		},
+		{
+			name:       "match operator string pattern",
+			expression: `"nginx-7d9f" =~ "^nginx-"`,
+			expected:   true,
+		},
+		{
+			name:       "match operator type mismatch",
+			expression: `3 =~ /3/`,
+			wantErr:    true,
+		},
+		{
+			name:       "missing comparison operator",
+			expression: `1 2`,
+			wantErr:    true,
+		},
+		{
+			name:       "conditional",
+			expression: `$pod.privileged ? $pod.user == "root" : $pod.user != "root"`,
+			data: map[string]interface{}{
+				"pod": map[string]interface{}{"privileged": false, "user": "nobody"},
+			},
+			expected: true,
+		},
+		{
+			name:       "conditional value",
+			expression: `(@len($ports) > 1 ? "many" : "few") == "many"`,
+			data:       map[string]interface{}{"ports": []interface{}{80, 443}},
+			expected:   true,
+		},
+		{
+			name:       "nested conditional",
+			expression: `($n < 0 ? "negative" : $n == 0 ? "zero" : "positive") == "zero"`,
+			data:       map[string]interface{}{"n": 0},
+			expected:   true,
+		},
+		{
+			// @undeclared would fail if the branch that isn't selected were evaluated
+			name:       "conditional is lazy",
+			expression: `true ? true : @undeclared()`,
+			expected:   true,
+		},
+		{
+			name:       "conditional requires boolean",
+			expression: `1 ? true : false`,
+			wantErr:    true,
+		},
+		{
+			name:       "case",
+			expression: `case when $n < 10 then "small" when $n < 100 then "medium" else "large" end == "medium"`,
+			data:       map[string]interface{}{"n": 42},
+			expected:   true,
+		},
+		{
+			name:       "case with subject",
+			expression: `case $kind when "Pod" then $spec.hostNetwork when "Deployment" then $spec.template.hostNetwork end == false`,
+			data: map[string]interface{}{
+				"kind": "Pod",
+				"spec": map[string]interface{}{"hostNetwork": false},
+			},
+			expected: true,
+		},
+		{
+			name:       "case without match is nil",
+			expression: `case 3 when 1 then "one" when 2 then "two" end == nil`,
+			expected:   true,
+		},
+		{
+			name:       "case is lazy",
+			expression: `case when true then true when @undeclared() then false else @undeclared() end`,
+			expected:   true,
+		},
+		{
+			name:       "let",
+			expression: `let $n = @len($procs) in $n > 0 && $n <= 2`,
+			data:       map[string]interface{}{"procs": []interface{}{"a", "b"}},
+			expected:   true,
+		},
+		{
+			name:       "let bindings are visible in select predicates",
+			expression: `let $t = 1 in @select($x, "$ > $t") == [2, 3] && @select($x, "let $t = 2 in $ > $t") == [3]`,
+			data:       map[string]interface{}{"x": []interface{}{1, 2, 3}},
+			expected:   true,
+		},
+		{
+			name:       "let multiple bindings",
+			expression: `let $web = @select($pods, "$tier == \"web\""), $n = @len($web) in $n == 1`,
+			data: map[string]interface{}{
+				"pods": []interface{}{
+					map[string]interface{}{"tier": "web"},
+					map[string]interface{}{"tier": "db"},
+				},
+			},
+			expected:   true,
+		},
+		{
+			name:       "let binding path",
+			expression: `let $c = $spec.containers[0], $ports = $c.ports in $c.name == "nginx" && $ports[1] == 443`,
+			data: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "nginx", "ports": []interface{}{80, 443}},
+					},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "let shadows data",
+			expression: `(let $x = 1 in $x == 1) && $x == 2`,
+			data:       map[string]interface{}{"x": 2},
+			expected:   true,
+		},
+		{
+			name:       "nested let shadows outer binding",
+			expression: `let $x = 1 in (let $x = "inner" in $x == "inner") && $x == 1`,
+			expected:   true,
+		},
+		{
+			name:       "let binding subexpression",
+			expression: `let $ok = (1 < 2 && 3 < 4), $labels = {"app": "web"} in $ok && "app" in $labels`,
+			expected:   true,
+		},
+		{
+			name:       "let invalid binding name",
+			expression: `let $x.y = 1 in true`,
+			wantErr:    true,
+		},
+		{
+			name: "multi-line expression with comments",
+			expression: `
+// warn when a small number of processes run without a binary on disk
+let $n = @len($procs) in /* count once */
+	$n > 0 &&
+	$n <= 2 // more than two is a failure
+`,
+			data:     map[string]interface{}{"procs": []interface{}{"a"}},
+			expected: true,
+		},
+		{
+			name:       "carriage return line endings",
+			expression: "1 < 2 &&\r\n2 < 3\r\n",
+			expected:   true,
+		},
+		{
+			name:       "comment markers in strings are not comments",
+			expression: `@len(["http://example.com", "/* x */"]) == 2`,
+			expected:   true,
+		},
+		{
+			name:       "unterminated comment",
+			expression: `true /* never closed`,
+			wantErr:    true,
+		},
+		{
+			name:       "regex alternation",
+			expression: `@match("bar", /^(foo|bar)$/) && "baz" !~ /^(foo|bar)$/`,
+			expected:   true,
+		},
+		{
+			name:       "regex character classes and spaces",
+			expression: `"key = some value" =~ /^\w+\s+=\s+some value$/ && "a_b#c'd" =~ /_b#c'/`,
+			expected:   true,
+		},
+		{
+			name:       "regex escaped slash",
+			expression: `$path =~ /^\/usr\/(local\/)?bin\// && $path !~ /^\/tmp\//`,
+			data:       map[string]interface{}{"path": "/usr/local/bin/nginx"},
+			expected:   true,
+		},
+		{
+			name:       "regex literal compares as its pattern",
+			expression: `/abc/ == "abc" && /^a/i == "(?i)^a" && /abc/ != "abd" && "abc" in [/abc/] && [/a/] == @array(/a/) && @select(@array(/a/), "true") == ["a"] && @type([/a/][0]) == @type(/a/) && @match("a", /a/) && @match("a", [/a/][0])`,
+			expected:   true,
+		},
+		{
+			name:       "regex flags",
+			expression: `"NGINX" =~ /^nginx$/i && "a\nb" =~ /^b$/m && "a\nb" =~ /a.b/s && "a\nb" !~ /a.b/`,
+			expected:   true,
+		},
+		{
+			name:       "invalid regex",
+			expression: `"a" =~ /(a/`,
+			wantErr:    true,
+		},
+		{
+			name:       "match with pattern from data",
+			expression: `@match($name, $pattern) && $name =~ $pattern && @len(@select($names, "@match($, \"^kube-\")")) == 2`,
+			data: map[string]interface{}{
+				"name":    "kube-proxy",
+				"pattern": "^kube-(proxy|dns)$",
+				"names":   []interface{}{"kube-proxy", "nginx", "kube-dns"},
+			},
+			expected: true,
+		},
+		{
+			name:       "invalid pattern from data",
+			expression: `@match("a", $pattern)`,
+			data:       map[string]interface{}{"pattern": "(a"},
+			wantErr:    true,
+		},
+		{
+			name:       "capture groups",
+			expression: `@capture($version, /^OpenSSL (\d+)\.(\d+)\.(\d+)([a-z])?/) == ["1", "1", "1", "k"]`,
+			data:       map[string]interface{}{"version": "OpenSSL 1.1.1k  25 Mar 2021"},
+			expected:   true,
+		},
+		{
+			name:       "capture unmatched optional group",
+			expression: `@capture("3.0.2", /^(\d+)\.(\d+)\.(\d+)([a-z])?$/) == ["3", "0", "2", nil]`,
+			expected:   true,
+		},
+		{
+			name:       "capture named groups",
+			expression: `@capture($image, /^(?P<registry>[^\/]+)\/(?P<name>[^:]+):(?P<tag>.+)$/) == {"registry": "registry.corp", "name": "nginx", "tag": "1.21"}`,
+			data:       map[string]interface{}{"image": "registry.corp/nginx:1.21"},
+			expected:   true,
+		},
+		{
+			name:       "capture without match",
+			expression: `@capture("nginx", /:(.+)$/) == nil`,
+			expected:   true,
+		},
+		{
+			name:       "find all",
+			expression: `@find_all("a=1, b=22, c=333", /\d+/) == ["1", "22", "333"] && @find_all("a=1, b=22", /(\w)=/) == ["a", "b"] && @len(@find_all("x", /\d/)) == 0`,
+			expected:   true,
+		},
+		{
+			name:       "replace regex",
+			expression: `@replace_re("host-01.corp", /^([a-z]+)-(\d+)/, "$2-$1") == "01-host.corp" && @replace_re("a  b   c", "\\s+", " ") == "a b c"`,
+			expected:   true,
+		},
+		{
+			name:       "glob star does not cross directories",
+			expression: `@glob("/tmp/x", "/tmp/*") && !@glob("/tmp/a/x", "/tmp/*") && @glob("/etc/nginx.conf", "/etc/*.conf")`,
+			expected:   true,
+		},
+		{
+			name:       "glob double star",
+			expression: `@glob("/tmp/a/b/c", "/tmp/**") && @glob("/srv/app.py", "/srv/**/*.py") && @glob("/srv/a/b/app.py", "/srv/**/*.py") && !@glob("/srv/app.pyc", "/srv/**/*.py")`,
+			expected:   true,
+		},
+		{
+			name:       "glob question mark and classes",
+			expression: `@glob("/dev/sda1", "/dev/sd[a-c]?") && !@glob("/dev/sdd1", "/dev/sd[a-c]?") && @glob("log.2", "log.[!0]") && !@glob("log.0", "log.[^0]")`,
+			expected:   true,
+		},
+		{
+			name:       "glob escapes and regex characters",
+			expression: `@glob("a*b", "a\\*b") && !@glob("axb", "a\\*b") && @glob("(x).+", "(x).+")`,
+			expected:   true,
+		},
+		{
+			name:       "select with glob",
+			expression: `@len(@select($images, "!@glob($, \"registry.corp/*\")")) == 1`,
+			data:       map[string]interface{}{"images": []interface{}{"registry.corp/nginx:1.21", "docker.io/redis:6"}},
+			expected:   true,
+		},
+		{
+			name:       "fnmatch star matches slash",
+			expression: `@fnmatch("registry.corp/team/nginx:1.21", "registry.corp/*") && !@glob("registry.corp/team/nginx:1.21", "registry.corp/*") && @fnmatch("a/b", "a?b")`,
+			expected:   true,
+		},
+		{
+			name:       "glob unterminated class",
+			expression: `@glob("a", "[a")`,
+			wantErr:    true,
+		},
+		{
+			name:       "keys and values",
+			expression: `@keys($data) == ["log_level", "port"] && @values($data) == ["debug", 8080] && @len(@keys({})) == 0`,
+			data: map[string]interface{}{
+				"data": map[string]interface{}{"port": 8080, "log_level": "debug"},
+			},
+			expected: true,
+		},
+		{
+			name:       "entries",
+			expression: `@entries($data) == [{"key": "a", "value": "x"}, {"key": "b", "value": ""}] && @len(@select(@entries($data), "$value == \"\"")) == 1`,
+			data: map[string]interface{}{
+				"data": map[string]interface{}{"b": "", "a": "x"},
+			},
+			expected: true,
+		},
+		{
+			name:       "required keys missing",
+			expression: `@difference(["app", "owner"], @keys($labels)) == ["owner"]`,
+			data: map[string]interface{}{
+				"labels": map[string]interface{}{"app": "web", "tier": "frontend"},
+			},
+			expected: true,
+		},
+		{
+			name:       "type",
+			expression: `@type($n) == "number" && @type("x") == "string" && @type(true) == "bool" && @type(nil) == "nil" && @type([]) == "array" && @type({}) == "object" && @type(/x/) == "string"`,
+			data:       map[string]interface{}{"n": 1},
+			expected:   true,
+		},
+		{
+			name:       "is array and is object",
+			expression: `@is_array($ports) && !@is_object($ports) && @is_object($labels) && !@is_array("x")`,
+			data: map[string]interface{}{
+				"ports":  []interface{}{80},
+				"labels": map[string]interface{}{},
+			},
+			expected: true,
+		},
+		{
+			name:       "keys of array",
+			expression: `@len(@keys([1])) == 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "top processes by memory",
+			expression: `@take(@sort($procs, "mem", "desc"), 2) == [{"name": "java", "mem": 2048}, {"name": "postgres", "mem": 512}]`,
+			data: map[string]interface{}{
+				"procs": []interface{}{
+					map[string]interface{}{"name": "sshd", "mem": 12},
+					map[string]interface{}{"name": "java", "mem": 2048},
+					map[string]interface{}{"name": "postgres", "mem": 512},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "sort values",
+			expression: `@sort([3, 1, 2]) == [1, 2, 3] && @sort(["b", "a"], "", "desc") == ["b", "a"] && @sort([1, "a", nil, true]) == [nil, true, 1, "a"]`,
+			expected:   true,
+		},
+		{
+			name:       "sort go numbers",
+			expression: `@sort($nums) == [1, 1, 2, 2.5, 3] && @first(@sort($procs, "mem", "desc")).name == "java"`,
+			data: map[string]interface{}{
+				"nums": []interface{}{3, intPtr(1), 2.5, 1, 2},
+				"procs": []interface{}{
+					map[string]interface{}{"name": "sshd", "mem": 12},
+					map[string]interface{}{"name": "java", "mem": floatPtr(2048)},
+					map[string]interface{}{"name": "postgres", "mem": 512.5},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "sort bad direction",
+			expression: `@len(@sort([1], "", "up")) == 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "reverse first last",
+			expression: `@reverse([1, 2, 3]) == [3, 2, 1] && @first([1, 2]) == 1 && @last([1, 2]) == 2 && @first([]) == nil`,
+			expected:   true,
+		},
+		{
+			name:       "flatten",
+			expression: `@flatten([1, [2, [3, [4]]]]) == [1, 2, 3, 4] && @flatten([1, [2, [3]]], 1) == [1, 2, [3]]`,
+			expected:   true,
+		},
+		{
+			name:       "distinct",
+			expression: `@distinct(@flatten([$a, $b])) == ["x", "y", "z"]`,
+			data: map[string]interface{}{
+				"a": []interface{}{"x", "y"},
+				"b": []interface{}{"y", "z"},
+			},
+			expected: true,
+		},
+		{
+			name:       "take and skip",
+			expression: `@take([1, 2, 3], 2) == [1, 2] && @skip([1, 2, 3], 2) == [3] && @take([1], 5) == [1] && @skip([1], 5) == []`,
+			expected:   true,
+		},
+		{
+			name:       "take negative",
+			expression: `@len(@take([1], -1)) == 0`,
+			wantErr:    true,
+		},
+		{
+			name:       "zip",
+			expression: `@zip(["a", "b", "c"], [1, 2]) == [["a", 1], ["b", 2]]`,
+			expected:   true,
+		},
+		{
+			name:       "range",
+			expression: `@range(3) == [0, 1, 2] && @range(1, 7, 3) == [1, 4] && @range(3, 0, -1) == [3, 2, 1] && @range(0) == []`,
+			expected:   true,
+		},
+		{
+			name:       "range zero step",
+			expression: `@len(@range(0, 3, 0)) == 0`,
+			wantErr:    true,
+		},
+		{
+			name:       "range longest",
+			expression: `@len(@range(1000000)) == 1000000 && @len(@range(0, -2000000, -2)) == 1000000 && @range(-1, 2, 2) == [-1, 1]`,
+			expected:   true,
+		},
+		{
+			name:       "range too long",
+			expression: `@len(@range(1000001)) == 0`,
+			wantErr:    true,
+		},
+		{
+			name:       "range huge bounds",
+			expression: `@len(@range(-9007199254740992, 9007199254740992, 9007199254740992)) == 2`,
+			expected:   true,
+		},
+		{
+			name:       "range bound out of range",
+			expression: `@len(@range(100000000000000000000)) == 0`,
+			wantErr:    true,
+		},
+		{
+			name:       "take count out of range",
+			expression: `@len(@take([1], 10000000000000000000)) == 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "no user owns more than 2 listeners",
+			expression: `@count_by($listeners, "user") == {"root": 2, "www-data": 1} && @len(@select(@entries(@count_by($listeners, "user")), "$value > 2")) == 0`,
+			data: map[string]interface{}{
+				"listeners": []interface{}{
+					map[string]interface{}{"user": "root", "port": 22},
+					map[string]interface{}{"user": "www-data", "port": 80},
+					map[string]interface{}{"user": "root", "port": 25},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "every namespace has a network policy",
+			expression: `@subset($namespaces, @keys(@group_by($policies, "metadata.namespace")))`,
+			data: map[string]interface{}{
+				"namespaces": []interface{}{"default", "prod"},
+				"policies": []interface{}{
+					map[string]interface{}{"metadata": map[string]interface{}{"namespace": "prod", "name": "deny-all"}},
+					map[string]interface{}{"metadata": map[string]interface{}{"namespace": "prod", "name": "allow-web"}},
+				},
+			},
+			expected: false,
+		},
+		{
+			name:       "group by",
+			expression: `@group_by([{"a": 1, "b": "x"}, {"a": 2, "b": "y"}, {"a": 1, "b": "z"}, {"b": "w"}], "a") == {"1": [{"a": 1, "b": "x"}, {"a": 1, "b": "z"}], "2": [{"a": 2, "b": "y"}], "nil": [{"b": "w"}]}`,
+			expected:   true,
+		},
+		{
+			name:       "group go numbers",
+			expression: `@group_by($nums, "") == {"1": [1, 1], "2": [2]} && @count_by($nums, "") == {"1": 2, "2": 1} && @index_by($nums, "") == {"1": 1, "2": 2} && @count_by($procs, "pid") == {"7": 2}`,
+			data: map[string]interface{}{
+				"nums": []interface{}{1, intPtr(1), 2},
+				"procs": []interface{}{
+					map[string]interface{}{"pid": 7},
+					map[string]interface{}{"pid": floatPtr(7)},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "index by",
+			expression: `@index_by([{"id": "a", "v": 1}, {"id": "b", "v": 2}, {"id": "a", "v": 3}], "id") == {"a": {"id": "a", "v": 3}, "b": {"id": "b", "v": 2}}`,
+			expected:   true,
+		},
+		{
+			name:       "group by array value",
+			expression: `@len(@group_by([{"a": [1]}], "a")) == 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "processes listening on privileged ports",
+			expression: `@len(@select(@join($processes, $listening_ports, "pid", "pid"), "$port < 1024 && $name != \"sshd\"")) == 1`,
+			data: map[string]interface{}{
+				"processes": []interface{}{
+					map[string]interface{}{"pid": "1", "name": "sshd"},
+					map[string]interface{}{"pid": "2", "name": "nc"},
+					map[string]interface{}{"pid": "3", "name": "bash"},
+				},
+				"listening_ports": []interface{}{
+					map[string]interface{}{"pid": "1", "port": 22},
+					map[string]interface{}{"pid": "2", "port": 23},
+					map[string]interface{}{"pid": "2", "port": 8080},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "join",
+			expression: `@join([{"id": 1, "a": "x"}, {"id": 2, "a": "y"}, {"a": "z"}], [{"ref": 1, "a": "w", "b": true}, {"ref": "2"}], "id", "ref") == [{"id": 1, "ref": 1, "a": "x", "b": true}]`,
+			expected:   true,
+		},
+		{
+			name:       "left join",
+			expression: `@join([{"id": 1}, {"id": 2}], [{"id": 1, "b": 1}], "id", "id", "left") == [{"id": 1, "b": 1}, {"id": 2}]`,
+			expected:   true,
+		},
+		{
+			name:       "join non object",
+			expression: `@len(@join([1], [], "id", "id")) == 0`,
+			wantErr:    true,
+		},
+		{
+			name:       "lookup",
+			expression: `@lookup($ports, "port", 22) == {"port": 22, "pid": 1} && @lookup($ports, "port", 80) == nil`,
+			data: map[string]interface{}{
+				"ports": []interface{}{
+					map[string]interface{}{"port": 22, "pid": 1},
+					map[string]interface{}{"port": 22, "pid": 2},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "osquery string columns",
+			expression: `@len(@select($processes, "@number($pid) > 100 && @bool($on_disk)")) == 1`,
+			data: map[string]interface{}{
+				"processes": []interface{}{
+					map[string]interface{}{"pid": "1", "on_disk": "1"},
+					map[string]interface{}{"pid": "20", "on_disk": "1"},
+					map[string]interface{}{"pid": "1234", "on_disk": "1"},
+					map[string]interface{}{"pid": "4321", "on_disk": "0"},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "number",
+			expression: `@number("-1.5") == -1.5 && @number("1e3") == 1000 && @number(7) == 7`,
+			expected:   true,
+		},
+		{
+			name:       "number not strict",
+			expression: `@number(" 42") == 42`,
+			wantErr:    true,
+		},
+		{
+			name:       "number of bool",
+			expression: `@number(true) == 1`,
+			wantErr:    true,
+		},
+		{
+			name:       "int",
+			expression: `@int("42") == 42 && @int(-2.7) == -2 && @int("+3") == 3`,
+			expected:   true,
+		},
+		{
+			name:       "int of decimal string",
+			expression: `@int("4.2") == 4`,
+			wantErr:    true,
+		},
+		{
+			name:       "string",
+			expression: `@string(8080) == "8080" && @string(0.5) == "0.5" && @string(false) == "false" && @string("x") == "x"`,
+			expected:   true,
+		},
+		{
+			name:       "string of array",
+			expression: `@string([]) == ""`,
+			wantErr:    true,
+		},
+		{
+			name:       "bool",
+			expression: `@bool("true") && !@bool("0") && @bool(1) && !@bool(false) && @bool("tRuE") && !@bool("FaLsE") && @bool("T")`,
+			expected:   true,
+		},
+		{
+			name:       "bool of other number",
+			expression: `@bool(2)`,
+			wantErr:    true,
+		},
+		{
+			name:       "secret contains default password",
+			expression: `@base64_decode($secret.data.password) == "changeme" && @base64_encode("changeme") == $secret.data.password`,
+			data: map[string]interface{}{
+				"secret": map[string]interface{}{
+					"data": map[string]interface{}{"password": "Y2hhbmdlbWU="},
+				},
 			},
 			expected: true,
 		},
 		{
-			name:       "has function",
-			expression: `@has($bar, "foo") && $bar.foo == 3`,
+			name:       "invalid base64",
+			expression: `@base64_decode("not base64!") == ""`,
+			wantErr:    true,
+		},
+		{
+			name:       "digests",
+			expression: `@sha256("abc") == "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" && @md5("abc") == "900150983cd24fb0d6963f7d28e17f72"`,
+			expected:   true,
+		},
+		{
+			name:       "hex and url decode",
+			expression: `@hex("hi") == "6869" && @url_decode("a%2Fb+c") == "a/b c"`,
+			expected:   true,
+		},
+		{
+			name:       "json parse",
+			expression: `@json_parse($cm.data["config"]) == {"logging": {"level": "debug"}, "replicas": 3, "tags": ["a"]}`,
 			data: map[string]interface{}{
-				"bar": map[string]interface{}{
-					"foo": 3,
+				"cm": map[string]interface{}{
+					"data": map[string]interface{}{"config": `{"logging": {"level": "debug"}, "replicas": 3, "tags": ["a"]}`},
 				},
 			},
 			expected: true,
 		},
 		{
-			name:       "root object dotted reference",
-			expression: `3 < $foo`,
+			name:       "invalid json",
+			expression: `@json_parse("{") == nil`,
+			wantErr:    true,
+		},
+		{
+			name:       "yaml parse",
+			expression: `@yaml_parse($doc) == {"logging": {"level": "debug"}, "replicas": 3, "ports": [80, 443], "1": true}`,
 			data: map[string]interface{}{
-				"foo": 4,
+				"doc": "logging:\n  level: debug\nreplicas: 3\nports: [80, 443]\n1: true\n",
 			},
 			expected: true,
 		},
 		{
-			name: "match",
-			expression: `@match("10.10.10.10", /^([0-9]{1,3}\.){3}[0-9]{1,3}$/)`,
+			name:       "yaml parse 1.2 scalars",
+			expression: `@yaml_parse($doc) == {"b": ["x", "y", "on", "no", "yes", "off"], "date": "2001-12-14"}`,
+			data: map[string]interface{}{
+				"doc": "b: [x, y, on, no, yes, off]\ndate: 2001-12-14\n",
+			},
+			expected: true,
+		},
+		{
+			name:       "embedded json config",
+			expression: `@parse_json($cm.data["app.json"]).logging.level == "debug" && @parse_json($cm.data["app.json"])["replicas"] > 1`,
+			data: map[string]interface{}{
+				"cm": map[string]interface{}{
+					"data": map[string]interface{}{"app.json": `{"logging": {"level": "debug"}, "replicas": 3}`},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "embedded yaml config",
+			expression: `@parse_yaml($doc).spec.ports[1] == 443 && @parse_yaml($doc).spec.missing == nil && @parse_yaml($doc).end == true`,
+			data: map[string]interface{}{
+				"doc": "spec:\n  ports: [80, 443]\nend: true\n",
+			},
+			expected: true,
+		},
+		{
+			name:       "accessor on function result",
+			expression: `@sort(@keys($labels))[0] == "app" && @entries($labels)[1].value == "web"`,
+			data: map[string]interface{}{
+				"labels": map[string]interface{}{"tier": "web", "app": "nginx"},
+			},
+			expected: true,
+		},
+		{
+			name:       "accessor index out of range",
+			expression: `@keys({})[0] == "a"`,
+			wantErr:    true,
+		},
+		{
+			name:       "accessor field of array",
+			expression: `@keys({"a": 1}).a == nil`,
+			wantErr:    true,
+		},
+		{
+			name:       "quoted key containing dots",
+			expression: `$annotations["kubernetes.io/ingress.class"] == "nginx" && $annotations["a]"] == 1`,
+			data: map[string]interface{}{
+				"annotations": map[string]interface{}{"kubernetes.io/ingress.class": "nginx", "a]": 1},
+			},
+			expected: true,
+		},
+		{
+			name:       "field of selected element",
+			expression: `@first(@select($pods, "$ready")).name == "web-1" && !@last($pods).ready`,
+			data: map[string]interface{}{
+				"pods": []interface{}{
+					map[string]interface{}{"name": "web-0", "ready": false},
+					map[string]interface{}{"name": "web-1", "ready": true},
+					map[string]interface{}{"name": "web-2", "ready": false},
+				},
+			},
+			expected: true,
+		},
+		{
+			name:       "accessors on literals and subexpressions",
+			expression: `(@array(1, 2, 3))[0] == 1 && [[1, 2], [3]][0][1] == 2 && {"a": {"b": "c"}}.a["b"] == "c" && {"a": 1}.b == nil`,
+			expected:   true,
+		},
+		{
+			name:       "accessor on variable",
+			expression: `$containers[0] .image == "nginx" && ($containers)[0]["image"] == "nginx"`,
+			data: map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{"image": "nginx"}},
+			},
 			expected: true,
 		},
+		{
+			name:       "accessor missing key",
+			expression: `{"a": 1}["b"] == nil`,
+			wantErr:    true,
+		},
+		{
+			name:       "accessor on scalar",
+			expression: `"abc"[0] == "a"`,
+			wantErr:    true,
+		},
 	}
 
 	for _, tc := range tt {
@@ -269,8 +1275,150 @@ func TestEval(t *testing.T) {
 			require.Nil(t, err)
 			require.Equal(t, tc.expected, actual)
 		})
+		t.Run(tc.name+" formatted", func(t *testing.T) {
+			testFormatRoundTrip(t, tc.expression, tc.data, tc.expected, tc.wantErr)
+		})
+	}
+
+}
+
+// testFormatRoundTrip checks that formatting expression doesn't change its meaning and that the formatted expression
+// is left unchanged when it is formatted again.
+func testFormatRoundTrip(t *testing.T, expression string, data interface{}, expected, wantErr bool) {
+	formatted, err := format.Source(expression)
+	if err != nil {
+		require.True(t, wantErr)
+		return
+	}
+	again, err := format.Source(formatted)
+	require.Nil(t, err)
+	require.Equal(t, formatted, again)
+
+	ctx, err := context.New(data)
+	require.Nil(t, err)
+	actual, err := EvaluateContext(ctx, formatted)
+	if wantErr {
+		require.NotNil(t, err)
+		return
+	}
+	require.Nil(t, err, formatted)
+	require.Equal(t, expected, actual, formatted)
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := Prepare(`// leading comment
+$a == 1 &&
+	/* comment */ )`)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "3:16:")
+
+	_, err = Prepare(`$name =~ /^(nginx|redis$/`)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "1:10: invalid regular expression")
+}
+
+// TestKeywordCase checks that only nil, true and false are keywords in any case, the keywords added to the language
+// later are only recognized in lower case and upper case forms are ordinary identifiers.
+func TestKeywordCase(t *testing.T) {
+	symbols := ast.Parser().Lexer().Symbols()
+	tt := []struct {
+		word  string
+		token string
+	}{
+		{word: "TRUE", token: "Keyword"},
+		{word: "Nil", token: "Keyword"},
+		{word: "not", token: "Keyword"},
+		{word: "in", token: "Keyword"},
+		{word: "NOT", token: "Ident"},
+		{word: "In", token: "Ident"},
+		{word: "end", token: "Keyword"},
+		{word: "End", token: "Ident"},
+		{word: "CASE", token: "Ident"},
+		{word: "let", token: "Keyword"},
+		{word: "LET", token: "Ident"},
+	}
+	for _, tc := range tt {
+		lex, err := ast.Parser().Lexer().Lex("", strings.NewReader(tc.word))
+		require.Nil(t, err)
+		tok, err := lex.Next()
+		require.Nil(t, err)
+		require.Equal(t, symbols[tc.token], tok.Type, tc.word)
+	}
+	for _, expression := range []string{`"a" NOT IN ["b"]`, `"a" Not In ["b"]`, `CASE WHEN true THEN true END`, `LET $n = 1 in $n == 1`} {
+		_, err := Prepare(expression)
+		require.NotNil(t, err, expression)
+	}
+	actual, err := Evaluate(nil, `{"End": 1, "IN": 2}.End == 1 && {"IN": 2}.IN == 2`)
+	require.Nil(t, err)
+	require.True(t, actual)
+}
+
+func TestRegexpCacheSize(t *testing.T) {
+	_, err := context.New(nil, context.RegexpCacheSize(-1))
+	require.NotNil(t, err)
+
+	var pods []interface{}
+	for i := 0; i < 50; i++ {
+		pods = append(pods, map[string]interface{}{
+			"name":    fmt.Sprintf("pod-%d", i),
+			"pattern": fmt.Sprintf("^pod-%d$", i%5),
+		})
+	}
+	for _, size := range []int{0, 1, context.DefaultRegexpCacheSize} {
+		// the context and its cache are shared by all goroutines, a cache size of 1 forces constant evictions
+		ctx, err := context.New(map[string]interface{}{"pods": pods}, context.RegexpCacheSize(size))
+		require.Nil(t, err)
+		g := new(errgroup.Group)
+		for i := 0; i < 20; i++ {
+			g.Go(func() error {
+				actual, err := EvaluateContext(ctx, `@len(@select($pods, "$name =~ $pattern")) == 5`)
+				if err != nil {
+					return err
+				}
+				if !actual {
+					return fmt.Errorf("cache size %d: unexpected result", size)
+				}
+				return nil
+			})
+		}
+		require.Nil(t, g.Wait())
 	}
+}
 
+func TestCoerceNumericStrings(t *testing.T) {
+	data := map[string]interface{}{"pid": "1234", "name": "nginx", "port": 80}
+	tt := []struct {
+		expression string
+		coerce     bool
+		expected   bool
+		wantErr    bool
+	}{
+		{expression: `$pid > 100`, wantErr: true},
+		{expression: `$pid == 1234`, wantErr: true},
+		{expression: `$pid > 100 && $pid <= 1234 && 2000 > $pid`, coerce: true, expected: true},
+		{expression: `$pid == 1234 && $port == "80" && $port != "81"`, coerce: true, expected: true},
+		// both operands are strings so they still compare lexically
+		{expression: `$pid < "200"`, coerce: true, expected: true},
+		{expression: `$name > 100`, coerce: true, wantErr: true},
+		{expression: `@len(@select([{"pid": "7"}], "$pid > 5")) == 1`, coerce: true, expected: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expression, func(t *testing.T) {
+			var opts []context.Option
+			if tc.coerce {
+				opts = append(opts, context.CoerceNumericStrings())
+			}
+			ctx, err := context.New(data, opts...)
+			require.Nil(t, err)
+			actual, err := EvaluateContext(ctx, tc.expression)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
 }
 
 func TestUserDefinedFunctions(t *testing.T) {
@@ -304,6 +1452,21 @@ func TestUserDefinedFunctions(t *testing.T) {
 			data:       2,
 			expected:   true,
 		},
+		{
+			name: "regex literal arg",
+			fns: map[string]ast.UserDefinedFunc{
+				"@pattern": func(a []interface{}) (interface{}, error) {
+					s, ok := a[0].(string)
+					if !ok {
+						return nil, fmt.Errorf("expected string got %T", a[0])
+					}
+					return s, nil
+				},
+			},
+			expression: `@pattern(/^nginx/i) == "(?i)^nginx" && $foo =~ @pattern(/^ngin/)`,
+			data:       map[string]interface{}{"foo": "nginx"},
+			expected:   true,
+		},
 	}
 
 	for _, tc := range tt {
@@ -373,3 +1536,224 @@ func TestPreparedExpression(t *testing.T) {
 	require.Nil(t, g.Wait())
 
 }
+
+func TestPreparedExpressionReferences(t *testing.T) {
+	tt := []struct {
+		name       string
+		expression string
+		variables  []string
+		predicates []string
+		functions  []string
+	}{
+		{
+			name:       "variables and functions",
+			expression: `$spec.replicas > 1 && @len($spec.containers) == @len($spec.containers) && $metadata["app.kubernetes.io/name"] != nil`,
+			variables:  []string{`metadata["app.kubernetes.io/name"]`, "spec.containers", "spec.replicas"},
+			predicates: []string{},
+			functions:  []string{"@len"},
+		},
+		{
+			name:       "select predicate",
+			expression: `@len(@select($pods, "$status.phase == \"Running\" && @has($labels, \"app\")")) > 0`,
+			variables:  []string{"pods"},
+			predicates: []string{"labels", "status.phase"},
+			functions:  []string{"@has", "@len", "@select"},
+		},
+		{
+			name:       "nested predicates",
+			expression: `$pods[0].name == "web" && @len(@select($pods, "$name == \"web\" && @len(@select($ports, \"$ > 80\")) > 0")) > 0`,
+			variables:  []string{"pods", "pods[0].name"},
+			predicates: []string{"", "name", "ports"},
+			functions:  []string{"@len", "@select"},
+		},
+		{
+			name:       "let bindings",
+			expression: `let $n = @len($items), $m = $n in $n > 0 && $m.x == $limit && (let $limit = 1 in $limit > 0)`,
+			variables:  []string{"items", "limit"},
+			predicates: []string{},
+			functions:  []string{"@len"},
+		},
+		{
+			name:       "let bindings are visible in predicates",
+			expression: `let $a = 1 in @len(@select($items, "$a == 1 && $b == $a")) == $a`,
+			variables:  []string{"items"},
+			predicates: []string{"b"},
+			functions:  []string{"@len", "@select"},
+		},
+		{
+			name:       "no references",
+			expression: `1 < 2`,
+			variables:  []string{},
+			predicates: []string{},
+			functions:  []string{},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			expression, err := Prepare(tc.expression)
+			require.Nil(t, err)
+			require.Equal(t, tc.variables, expression.Variables())
+			require.Equal(t, tc.predicates, expression.PredicateVariables())
+			require.Equal(t, tc.functions, expression.Functions())
+		})
+	}
+}
+
+func TestPreparedExpressionValidate(t *testing.T) {
+	ctx, err := context.New(nil, context.Func("@abs", func([]interface{}) (interface{}, error) { return 0, nil }))
+	require.Nil(t, err)
+
+	expression, err := Prepare(`@abs($n) > 1 && @len(@select($items, "@abs($x) > 0"))`)
+	require.Nil(t, err)
+	require.Nil(t, expression.Validate(ctx))
+
+	expression, err = Prepare(`$ok || @len(@select($items, "@missing($x)")) > 0`)
+	require.Nil(t, err)
+	err = expression.Validate(ctx)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), `"@missing"`)
+
+	expression, err = Prepare(`@len(@select($items, "$x ==")) > 0`)
+	require.Nil(t, err)
+	require.NotNil(t, expression.Validate(ctx))
+}
+
+func TestEvaluateValue(t *testing.T) {
+	data := map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": 3, "image": "nginx:1.21"},
+	}
+	tt := []struct {
+		expression string
+		expected   interface{}
+		wantErr    bool
+	}{
+		{expression: `$spec.replicas >= 2`, expected: true},
+		{expression: `$spec.replicas`, expected: float64(3)},
+		{expression: `@keys($spec)`, expected: []interface{}{"image", "replicas"}},
+		{expression: `$spec`, expected: data["spec"]},
+		{expression: `$spec.missing`, expected: nil},
+		{expression: `$spec.replicas > "x"`, wantErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expression, func(t *testing.T) {
+			ctx, err := context.New(data)
+			require.Nil(t, err)
+			actual, err := EvaluateValue(ctx, tc.expression)
+			if tc.wantErr {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tc.expected, actual)
+
+			prepared, err := Prepare(tc.expression)
+			require.Nil(t, err)
+			actual, err = prepared.EvaluateValue(ctx)
+			require.Nil(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+// TestNonBoolPredicate checks that predicates which don't evaluate to a bool return a type mismatch rather than
+// panicking.
+func TestNonBoolPredicate(t *testing.T) {
+	data := map[string]interface{}{"n": 1, "arr": []interface{}{1, 2}}
+	requireTypeMismatch := func(t *testing.T, err error) {
+		require.NotNil(t, err)
+		e, ok := err.(errors.Error)
+		require.True(t, ok, err)
+		require.Equal(t, errors.TypeMismatch, e.Type(), err)
+	}
+	for _, expression := range []string{`$n`, `@keys({"a": 1})`, `"x"`, `nil`, `@len(@select($arr, "$"))`} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := Evaluate(data, expression)
+			requireTypeMismatch(t, err)
+
+			ctx, err := context.New(data)
+			require.Nil(t, err)
+			_, err = EvaluateContext(ctx, expression)
+			requireTypeMismatch(t, err)
+
+			prepared, err := Prepare(expression)
+			require.Nil(t, err)
+			_, err = prepared.Evaluate(ctx)
+			requireTypeMismatch(t, err)
+		})
+	}
+}
+
+func TestVar(t *testing.T) {
+	baseline := map[string]interface{}{"image": "nginx:1.21", "ports": []interface{}{80, 443}}
+	ctx, err := context.New(
+		map[string]interface{}{"image": "nginx:1.21", "port": 443},
+		context.Var("baseline", baseline),
+		context.Var("max_port", 1024),
+	)
+	require.Nil(t, err)
+	actual, err := EvaluateContext(ctx, `$image == $baseline.image && $port in $baseline.ports && $port < $max_port`)
+	require.Nil(t, err)
+	require.True(t, actual)
+
+	// let bindings shadow variables
+	actual, err = EvaluateContext(ctx, `let $max_port = 100 in $port > $max_port`)
+	require.Nil(t, err)
+	require.True(t, actual)
+
+	// variables are visible in select predicates and take precedence over the fields of each element
+	ctx, err = context.New(
+		map[string]interface{}{"ports": []interface{}{80, 443, 8080}},
+		context.Var("max_port", 1024),
+		context.Var("image", "nginx"),
+	)
+	require.Nil(t, err)
+	value, err := EvaluateValue(ctx, `@select($ports, "$ > $max_port")`)
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{8080}, value)
+	value, err = EvaluateValue(ctx, `@select([{"image": "redis"}], "$image == \"nginx\"")`)
+	require.Nil(t, err)
+	require.Equal(t, []interface{}{map[string]interface{}{"image": "redis"}}, value)
+
+	_, err = context.New(nil, context.Var("1st", 1))
+	require.NotNil(t, err)
+	_, err = context.New(nil, context.Var("a", 1), context.Var("a", 2))
+	require.NotNil(t, err)
+	_, err = context.New(nil, context.Var("a", struct{}{}))
+	require.NotNil(t, err)
+}
+
+// TestAliasErrors checks that functions sharing an implementation report errors under the name they were called by.
+func TestAliasErrors(t *testing.T) {
+	tt := []struct {
+		expression string
+		name       string
+	}{
+		{expression: `@distinct(1)`, name: "distinct"},
+		{expression: `@distinct([1], [2])`, name: "distinct"},
+		{expression: `@unique(1)`, name: "unique"},
+		{expression: `@union(1)`, name: "union"},
+		{expression: `@parse_json("{")`, name: "parse_json"},
+		{expression: `@parse_yaml(1)`, name: "parse_yaml"},
+		{expression: `@json_parse("{")`, name: "json_parse"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expression, func(t *testing.T) {
+			ctx, err := context.New(nil)
+			require.Nil(t, err)
+			_, err = EvaluateValue(ctx, tc.expression)
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tc.name)
+			if tc.name != "union" {
+				require.NotContains(t, err.Error(), "union")
+			}
+		})
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,151 @@
+package expression
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/murphybytes/analyze/errors"
+	"github.com/murphybytes/analyze/internal/ast"
+)
+
+// Variables returns the paths of the variables the expression refers to in the context data without the leading $,
+// sorted and without duplicates, for example "spec.containers[0].image". Names bound by let expressions are not
+// included. Variables in @select predicates are returned by PredicateVariables.
+func (p *PreparedExpression) Variables() []string {
+	refs := p.references()
+	return sortedSet(refs.variables)
+}
+
+// PredicateVariables returns the paths of the variables used by the predicates passed to @select as string literals,
+// sorted and without duplicates like Variables. The paths are relative to the elements of the array being filtered
+// rather than to the context data, "status.phase" for @select($pods, "$status.phase == \"Running\""), and $, the
+// element itself, is returned as "". Names bound by let expressions, in the predicate or around the @select, are not
+// included.
+func (p *PreparedExpression) PredicateVariables() []string {
+	refs := p.references()
+	return sortedSet(refs.predicateVariables)
+}
+
+// Functions returns the names of the functions the expression calls including the leading @, sorted and without
+// duplicates. Functions called by @select predicates written as string literals are included.
+func (p *PreparedExpression) Functions() []string {
+	refs := p.references()
+	return sortedSet(refs.functions)
+}
+
+// Validate checks that every function the expression calls is declared in ctx and that the @select predicates
+// written as string literals parse, without evaluating the expression.
+func (p *PreparedExpression) Validate(ctx ast.Context) error {
+	refs := p.references()
+	if len(refs.errs) > 0 {
+		return refs.errs[0]
+	}
+	for _, name := range sortedSet(refs.functions) {
+		if _, ok := ctx.Func(name); !ok {
+			return errors.New(errors.InvalidFunction, "function %q has not been declared", name)
+		}
+	}
+	return nil
+}
+
+type references struct {
+	variables map[string]bool
+	// predicateVariables holds the variables of @select predicates, which refer to the elements being filtered
+	predicateVariables map[string]bool
+	functions          map[string]bool
+	// errs holds the errors parsing @select predicates
+	errs []error
+}
+
+func (p *PreparedExpression) references() *references {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	refs := &references{
+		variables:          make(map[string]bool),
+		predicateVariables: make(map[string]bool),
+		functions:          make(map[string]bool),
+	}
+	refs.collect(&p.tree, nil)
+	return refs
+}
+
+// collect records the variables and functions referenced under node, bound holds the names bound by enclosing let
+// expressions.
+func (r *references) collect(node ast.Node, bound map[string]bool) {
+	switch t := node.(type) {
+	case *ast.Variable:
+		if name := rootName(string(*t)); !bound[name] {
+			r.variables[string(*t)] = true
+		}
+		return
+	case *ast.Let:
+		// each binding is visible to the bindings after it and to the body
+		scope := make(map[string]bool, len(bound)+len(t.Bindings))
+		for name := range bound {
+			scope[name] = true
+		}
+		for _, binding := range t.Bindings {
+			r.collect(binding, scope)
+			scope[string(binding.Name)] = true
+		}
+		r.collect(t.Body, scope)
+		return
+	case *ast.Function:
+		r.functions[t.Name] = true
+		if t.Name == "@select" && len(t.Args) == 2 {
+			if predicate, ok := stringLiteral(t.Args[1]); ok {
+				r.collectPredicate(predicate, bound)
+			}
+		}
+	}
+	for _, child := range node.Children() {
+		r.collect(child, bound)
+	}
+}
+
+// collectPredicate records the references of a @select predicate, names bound by let expressions enclosing the
+// @select are visible in the predicate. Its variables, and those of any predicates nested in it, are recorded as
+// predicate variables.
+func (r *references) collectPredicate(predicate string, bound map[string]bool) {
+	var tree ast.Expression
+	if err := ast.Parser().ParseString("", predicate, &tree); err != nil {
+		r.errs = append(r.errs, err)
+		return
+	}
+	inner := &references{
+		variables:          r.predicateVariables,
+		predicateVariables: r.predicateVariables,
+		functions:          r.functions,
+	}
+	inner.collect(&tree, bound)
+	r.errs = append(r.errs, inner.errs...)
+}
+
+// stringLiteral returns the string if expr is nothing more than a string literal.
+func stringLiteral(expr *ast.Expression) (string, bool) {
+	if len(expr.Right) > 0 || expr.Then != nil || len(expr.Left.Right) > 0 {
+		return "", false
+	}
+	un := expr.Left.Left
+	if un.Operator != nil || len(un.Accessors) > 0 || un.Value.String == nil {
+		return "", false
+	}
+	return *un.Value.String, true
+}
+
+// rootName returns the first segment of a variable path without any index, "pods" for "pods[0].name".
+func rootName(path string) string {
+	if i := strings.IndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func sortedSet(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for k := range set {
+		result = append(result, k)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,417 @@
+// Package format prints expressions in a canonical form. Operators are surrounded by single spaces, function
+// arguments and literal elements are separated by a comma and a space and strings are quoted as Go quotes them. Chains
+// of && and || that don't fit on a line are broken before each operator with the operands indented on the lines that
+// follow. Comments are kept with the operand of the chain they are next to, a comment on the same line as the end of an
+// operand follows it and a comment on a line of its own goes on the line before the operand that follows it. Chains
+// holding comments that need a line of their own are broken.
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/murphybytes/analyze/ast"
+	iast "github.com/murphybytes/analyze/internal/ast"
+)
+
+// MaxWidth is the length a line can reach, counting a tab as four characters, before a chain of && and || operators
+// is broken over several lines.
+const MaxWidth = 100
+
+const tabWidth = 4
+
+// bracketKey matches the ["key"] and [index] parts of a variable reference along with any spaces inside the brackets.
+var bracketKey = regexp.MustCompile(`\[\s*("[^"]*"|\d+)\s*\]`)
+
+// Source parses an expression and returns it formatted along with its comments.
+func Source(expression string) (string, error) {
+	root, err := ast.Parse(expression)
+	if err != nil {
+		return "", err
+	}
+	tokens, err := iast.Tokens(expression)
+	if err != nil {
+		return "", err
+	}
+	p := &printer{comments: attach(root, tokens)}
+	return strings.TrimSpace(trailingSpace.ReplaceAllString(p.format(root, 0), "\n")), nil
+}
+
+// trailingSpace matches the spaces and tabs at the end of a line, which are left where a comment breaks a line.
+var trailingSpace = regexp.MustCompile(`[ \t]+\n`)
+
+// Node returns the formatted source of node, which can be any node of a parsed expression. Nodes don't hold comments
+// so none are included.
+func Node(node ast.Node) string {
+	return (&printer{}).format(node, 0)
+}
+
+// printer formats nodes, comments holds the comments attached to the operands of chains.
+type printer struct {
+	comments map[ast.Node]*comments
+}
+
+// comments are attached to an operand of a chain, leading comments are written on the lines before the operand and
+// trailing comments after it.
+type comments struct {
+	leading  []string
+	trailing []string
+}
+
+// operand is an operand of a chain along with the offsets of its first token, of the first token of its value after
+// any operator and of the token that follows it.
+type operand struct {
+	node              ast.Node
+	start, value, end int
+}
+
+// attach assigns each comment in tokens to an operand of a chain in root. A comment on the same line as the last token
+// of an operand trails the operand, otherwise it leads the operand starting with the token after it. If no operand
+// starts there it trails the operand holding the token before it.
+func attach(root *ast.Expression, tokens []lexer.Token) map[ast.Node]*comments {
+	var operands []operand
+	ast.Inspect(root, func(node ast.Node) bool {
+		if e, ok := node.(*ast.Expression); ok {
+			left := e.Left
+			operands = append(operands, operand{node: left, start: left.Pos.Offset, value: left.Pos.Offset, end: left.EndPos.Offset})
+			for _, r := range e.Right {
+				operands = append(operands, operand{node: r, start: r.Pos.Offset, value: r.Value.Pos.Offset, end: r.EndPos.Offset})
+			}
+		}
+		return true
+	})
+	result := make(map[ast.Node]*comments)
+	var prev *lexer.Token
+	for i, tok := range tokens {
+		if !iast.IsComment(tok) {
+			prev = &tokens[i]
+			continue
+		}
+		node, lead := place(operands, prev, tok, nextToken(tokens[i+1:]))
+		c := result[node]
+		if c == nil {
+			c = &comments{}
+			result[node] = c
+		}
+		text := strings.TrimRight(tok.Value, " \t\r")
+		if lead {
+			c.leading = append(c.leading, text)
+		} else {
+			c.trailing = append(c.trailing, text)
+		}
+	}
+	return result
+}
+
+// place returns the operand comment is attached to and whether it leads the operand, prev and next are the tokens
+// either side of the comment that aren't comments, nil at the start and end of the expression.
+func place(operands []operand, prev *lexer.Token, comment lexer.Token, next *lexer.Token) (ast.Node, bool) {
+	if prev != nil && prev.Pos.Line+strings.Count(prev.Value, "\n") == comment.Pos.Line {
+		if node := ending(operands, prev, next); node != nil {
+			return node, false
+		}
+	}
+	if next != nil {
+		for _, o := range operands {
+			if o.start == next.Pos.Offset || o.value == next.Pos.Offset {
+				return o.node, true
+			}
+		}
+	}
+	if prev != nil {
+		return holding(operands, prev.Pos.Offset), false
+	}
+	return operands[0].node, true
+}
+
+// ending returns the innermost operand whose last token is prev, nil if prev doesn't end an operand.
+func ending(operands []operand, prev, next *lexer.Token) ast.Node {
+	var inner *operand
+	for i, o := range operands {
+		if o.start <= prev.Pos.Offset && prev.Pos.Offset < o.end && (next == nil || o.end <= next.Pos.Offset) {
+			if inner == nil || o.start >= inner.start {
+				inner = &operands[i]
+			}
+		}
+	}
+	if inner == nil {
+		return nil
+	}
+	return inner.node
+}
+
+// holding returns the innermost operand holding the token at offset. Tokens between the operands of a chain such as ?
+// and : are held by the operand that ends closest before them.
+func holding(operands []operand, offset int) ast.Node {
+	var inner, before *operand
+	for i, o := range operands {
+		switch {
+		case o.start <= offset && offset < o.end:
+			if inner == nil || o.start >= inner.start {
+				inner = &operands[i]
+			}
+		case o.end <= offset:
+			if before == nil || o.end > before.end {
+				before = &operands[i]
+			}
+		}
+	}
+	if inner != nil {
+		return inner.node
+	}
+	if before != nil {
+		return before.node
+	}
+	return operands[0].node
+}
+
+// nextToken returns the first token that isn't a comment.
+func nextToken(tokens []lexer.Token) *lexer.Token {
+	for i := range tokens {
+		if !iast.IsComment(tokens[i]) {
+			return &tokens[i]
+		}
+	}
+	return nil
+}
+
+// format returns the source of node, indent is the depth of indentation of lines that follow the first.
+func (p *printer) format(node ast.Node, indent int) string {
+	switch t := node.(type) {
+	case *ast.Expression:
+		return p.expression(t, indent)
+	case *ast.LogicalOpValue:
+		return t.Operator.String() + " " + p.format(t.Value, indent)
+	case *ast.ComparisonOpTerm:
+		parts := []string{p.format(t.Left, indent)}
+		for _, r := range t.Right {
+			parts = append(parts, p.format(r, indent))
+		}
+		return strings.Join(parts, " ")
+	case *ast.ComparisonOpValue:
+		return t.Operator.String() + " " + p.format(t.Value, indent)
+	case *ast.UnaryOpValue:
+		var b strings.Builder
+		if t.Operator != nil {
+			b.WriteString(t.Operator.String())
+		}
+		b.WriteString(p.format(t.Value, indent))
+		for _, a := range t.Accessors {
+			b.WriteString(a.String())
+		}
+		return b.String()
+	case *ast.Value:
+		return p.value(t, indent)
+	case *ast.Variable:
+		return "$" + bracketKey.ReplaceAllString(string(*t), "[$1]")
+	case *ast.RegularExpression:
+		return t.String()
+	case *ast.Accessor:
+		return t.String()
+	case *ast.Function:
+		return t.Name + "(" + p.list(t.Args, indent) + ")"
+	case *ast.ArrayLiteral:
+		return "[" + p.list(t.Elements, indent) + "]"
+	case *ast.ObjectLiteral:
+		fields := make([]string, 0, len(t.Fields))
+		for _, field := range t.Fields {
+			fields = append(fields, p.format(field, indent))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case *ast.ObjectField:
+		return strconv.Quote(t.Key) + ": " + p.format(t.Value, indent)
+	case *ast.Case:
+		parts := []string{"case"}
+		if t.Subject != nil {
+			parts = append(parts, p.format(t.Subject, indent))
+		}
+		for _, branch := range t.Branches {
+			parts = append(parts, p.format(branch, indent))
+		}
+		if t.Else != nil {
+			parts = append(parts, "else", p.format(t.Else, indent))
+		}
+		return strings.Join(append(parts, "end"), " ")
+	case *ast.CaseBranch:
+		return "when " + p.format(t.When, indent) + " then " + p.format(t.Then, indent)
+	case *ast.Let:
+		bindings := make([]string, 0, len(t.Bindings))
+		for _, binding := range t.Bindings {
+			bindings = append(bindings, p.format(binding, indent))
+		}
+		return "let " + strings.Join(bindings, ", ") + " in " + p.format(t.Body, indent)
+	case *ast.Binding:
+		return "$" + string(t.Name) + " = " + p.format(t.Value, indent)
+	}
+	panic(fmt.Sprintf("format: unexpected node %T", node))
+}
+
+// expression formats a chain of && and || operators with an optional conditional, the chain is broken over several
+// lines if it is too long to fit on one or holds comments that need a line of their own before its end. Comments
+// leading the first operand start on a new line so they aren't taken for comments trailing whatever comes before the
+// chain.
+func (p *printer) expression(e *ast.Expression, indent int) string {
+	lead := p.leading(e.Left, indent)
+	if lead != "" {
+		lead = "\n" + tabs(indent) + lead
+	}
+	line := p.operands(e, indent, " ")
+	if len(e.Right) == 0 && e.Then == nil {
+		return lead + line
+	}
+	if text := strings.TrimSuffix(strings.TrimRight(line, "\t"), "\n"); indent*tabWidth+len(text) <= MaxWidth &&
+		!strings.Contains(text, "\n") {
+		return lead + line
+	}
+	return lead + p.operands(e, indent+1, "\n"+tabs(indent+1))
+}
+
+// operands formats the operands of an expression along with the operators that precede them and joins them with sep.
+// When the operands are joined by line breaks the line breaks that end trailing line comments are dropped.
+func (p *printer) operands(e *ast.Expression, indent int, sep string) string {
+	parts := []string{p.trailing(e.Left, p.format(e.Left, indent), indent)}
+	for _, r := range e.Right {
+		parts = append(parts, p.leading(r, indent)+p.trailing(r, p.format(r, indent), indent))
+	}
+	if e.Then != nil {
+		parts = append(parts, "? "+p.format(e.Then, indent), ": "+p.format(e.Else, indent))
+	}
+	if strings.HasPrefix(sep, "\n") {
+		for i, part := range parts[:len(parts)-1] {
+			if endsLine(part) {
+				parts[i] = strings.TrimSuffix(strings.TrimRight(part, "\t"), "\n")
+			}
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// leading returns the comments leading node, each followed by a line break and the indentation of the line node
+// starts on.
+func (p *printer) leading(node ast.Node, indent int) string {
+	c := p.comments[node]
+	if c == nil || len(c.leading) == 0 {
+		return ""
+	}
+	nl := "\n" + tabs(indent)
+	return strings.Join(c.leading, nl) + nl
+}
+
+// trailing returns text followed by the comments trailing node, a line comment is followed by a line break.
+func (p *printer) trailing(node ast.Node, text string, indent int) string {
+	c := p.comments[node]
+	if c == nil || len(c.trailing) == 0 {
+		return text
+	}
+	sep := " "
+	if endsLine(text) {
+		sep = ""
+	}
+	for _, comment := range c.trailing {
+		text += sep + comment
+		sep = " "
+		if strings.HasPrefix(comment, "//") {
+			sep = "\n" + tabs(indent)
+		}
+	}
+	if sep != " " {
+		text += sep
+	}
+	return text
+}
+
+// endsLine reports whether s ends with a line break followed by indentation.
+func endsLine(s string) bool {
+	return strings.HasSuffix(strings.TrimRight(s, "\t"), "\n")
+}
+
+func tabs(n int) string {
+	return strings.Repeat("\t", n)
+}
+
+// list formats function arguments and array elements, chains broken inside them are indented one level deeper than
+// the chain they are part of.
+func (p *printer) list(exprs []*ast.Expression, indent int) string {
+	parts := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		parts = append(parts, p.format(expr, indent+1))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (p *printer) value(v *ast.Value, indent int) string {
+	switch {
+	case v.Number != nil:
+		return number(*v.Number)
+	case v.Regexp != nil:
+		return "/" + v.Regexp.String() + "/"
+	case v.String != nil:
+		return strconv.Quote(*v.String)
+	case v.Bool != nil:
+		return strconv.FormatBool(bool(*v.Bool))
+	case v.Subexpression != nil:
+		return "(" + p.format(v.Subexpression, indent) + ")"
+	case v.Variable != nil:
+		return p.format(v.Variable, indent)
+	case v.RegularExpression != nil:
+		return p.format(v.RegularExpression, indent)
+	case v.ArrayLiteral != nil:
+		return p.format(v.ArrayLiteral, indent)
+	case v.ObjectLiteral != nil:
+		return p.format(v.ObjectLiteral, indent)
+	case v.Case != nil:
+		return p.format(v.Case, indent)
+	case v.Let != nil:
+		return p.format(v.Let, indent)
+	case v.Function != nil:
+		return p.format(v.Function, indent)
+	case v.Object != nil:
+		return literal(v.Object)
+	case v.Array != nil:
+		return literal(v.Array)
+	}
+	return "nil"
+}
+
+// literal formats data held by a value, values only hold data after they have been evaluated or rewritten.
+func literal(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return number(t)
+	case int:
+		return strconv.Itoa(t)
+	case string:
+		return strconv.Quote(t)
+	case bool:
+		return strconv.FormatBool(t)
+	case []interface{}:
+		elts := make([]string, 0, len(t))
+		for _, elt := range t {
+			elts = append(elts, literal(elt))
+		}
+		return "[" + strings.Join(elts, ", ") + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fields := make([]string, 0, len(t))
+		for _, k := range keys {
+			fields = append(fields, strconv.Quote(k)+": "+literal(t[k]))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return fmt.Sprint(v)
+}
+
+// number formats a number without an exponent, which the expression syntax doesn't support.
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,135 @@
+package format
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	tt := []struct {
+		name       string
+		expression string
+		expected   string
+	}{
+		{
+			name:       "spacing",
+			expression: `$a==1&&  $b  !=  "x"||!$c`,
+			expected:   `$a == 1 && $b != "x" || !$c`,
+		},
+		{
+			name:       "numbers and strings",
+			expression: `@f( 2.50, +3, "tab\there", "http://host" )`,
+			expected:   `@f(2.5, 3, "tab\there", "http://host")`,
+		},
+		{
+			name:       "literals and accessors",
+			expression: `{ "a" : [1,2] , "b":{} }.a[ 0 ] == 1 && $x not   in [1] && $y !~ /^a/i`,
+			expected:   `{"a": [1, 2], "b": {}}.a[0] == 1 && $x not in [1] && $y !~ /^a/i`,
+		},
+		{
+			name:       "conditional case and let",
+			expression: `(case $k when "a" then 1 else 2 end)==1?let $n=@len($x),$m=$n in $m>0:false`,
+			expected:   `(case $k when "a" then 1 else 2 end) == 1 ? let $n = @len($x), $m = $n in $m > 0 : false`,
+		},
+		{
+			name:       "variable keys",
+			expression: `$x[ "a" ][0].b == $y[ 1 ]`,
+			expected:   `$x["a"][0].b == $y[1]`,
+		},
+		{
+			name: "long chain",
+			expression: `@len(@select($pods, "$status.phase == \"Running\"")) > 0 && $spec.replicas >= 3 && ` +
+				`$metadata.labels["app"] in ["web", "api"] && ($x || $y)`,
+			expected: strings.Join([]string{
+				`@len(@select($pods, "$status.phase == \"Running\"")) > 0`,
+				`	&& $spec.replicas >= 3`,
+				`	&& $metadata.labels["app"] in ["web", "api"]`,
+				`	&& ($x || $y)`,
+			}, "\n"),
+		},
+		{
+			name: "nested long chain",
+			expression: `$enabled && ($aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa == 1 || $bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb == 2 || ` +
+				`$cccccccccccccccccccccccccccccc == 3)`,
+			expected: strings.Join([]string{
+				`$enabled`,
+				`	&& ($aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa == 1`,
+				`		|| $bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb == 2`,
+				`		|| $cccccccccccccccccccccccccccccc == 3)`,
+			}, "\n"),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := Source(tc.expression)
+			require.Nil(t, err)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	tt := []struct {
+		name       string
+		expression string
+		expected   string
+	}{
+		{
+			name:       "trailing",
+			expression: "$a==1 /* one */ &&$b // two",
+			expected:   "$a == 1 /* one */ && $b // two",
+		},
+		{
+			name:       "trailing line comment breaks the chain",
+			expression: "$a==1 // one\n&&$b",
+			expected:   "$a == 1 // one\n\t&& $b",
+		},
+		{
+			name:       "own line",
+			expression: "// first\n$a &&\n  // second\n  $b",
+			expected:   "// first\n$a\n\t// second\n\t&& $b",
+		},
+		{
+			name:       "after an operator",
+			expression: "$a && // second\n$b",
+			expected:   "$a\n\t// second\n\t&& $b",
+		},
+		{
+			name:       "inside an operand",
+			expression: "$a == /* x */ 1 && $b",
+			expected:   "$a == 1 /* x */ && $b",
+		},
+		{
+			name:       "function arguments",
+			expression: "@f($x, // y\n$y) /* f */",
+			expected:   "@f($x,\n\t// y\n\t$y) /* f */",
+		},
+		{
+			name:       "nested chain",
+			expression: "($a // a\n|| $b) && $c",
+			expected:   "($a // a\n\t\t|| $b)\n\t&& $c",
+		},
+		{
+			name:       "end of expression",
+			expression: "$a\n// done",
+			expected:   "$a // done",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := Source(tc.expression)
+			require.Nil(t, err)
+			require.Equal(t, tc.expected, actual)
+			again, err := Source(actual)
+			require.Nil(t, err)
+			require.Equal(t, actual, again)
+		})
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	_, err := Source(`$a ==`)
+	require.NotNil(t, err)
+}
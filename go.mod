@@ -1,10 +1,19 @@
 module github.com/murphybytes/analyze
 
-go 1.17
+go 1.18
 
 require (
 	github.com/alecthomas/participle/v2 v2.0.0-alpha7
 	github.com/stretchr/testify v1.4.0
 )
 
-require golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+require (
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0 // indirect
+)
@@ -0,0 +1,61 @@
+package ast
+
+import (
+	"strconv"
+
+	"github.com/murphybytes/analyze/errors"
+)
+
+// Accessor selects part of a value, .name or ["name"] selects a field of an object and [2] an element of an array.
+// Accessors can follow any value and can be chained, @parse_json($doc).spec.containers[0].image for example.
+//nolint
+type Accessor struct {
+	Field *string `  "." @(Ident | Keyword)`
+	Key   *string `| "[" ( @String`
+	Index *int    `| @Number ) "]"`
+}
+
+// Resolve returns the part of val selected by the accessor. Accessors behave like the equivalent variable path
+// segments, a missing field selected by .name resolves to nil while a missing ["name"] key or an index past the end
+// of an array is an error.
+func (a *Accessor) Resolve(val interface{}) (interface{}, error) {
+	if a.Index != nil {
+		arr, ok := val.([]interface{})
+		if !ok {
+			return nil, errors.New(errors.TypeMismatch, "can't access %s of %T, expected array", a, val)
+		}
+		return elementAt(arr, *a.Index, a.String())
+	}
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.TypeMismatch, "can't access %s of %T, expected object", a, val)
+	}
+	if a.Key != nil {
+		return fieldOf(obj, *a.Key, a.String())
+	}
+	return obj[*a.Field], nil
+}
+
+func (a *Accessor) String() string {
+	switch {
+	case a.Field != nil:
+		return "." + *a.Field
+	case a.Key != nil:
+		return "[" + strconv.Quote(*a.Key) + "]"
+	}
+	return "[" + strconv.Itoa(*a.Index) + "]"
+}
+
+// access applies accessors to v in order.
+func access(v *Value, accessors []*Accessor) (*Value, error) {
+	val, err := valToInterface(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, accessor := range accessors {
+		if val, err = accessor.Resolve(val); err != nil {
+			return nil, err
+		}
+	}
+	return convertToValue(val)
+}
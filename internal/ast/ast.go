@@ -1,11 +1,21 @@
 // Package ast contains the components of an abstract syntax tree that represents a predicate Expression.
 package ast
 
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/murphybytes/analyze/errors"
+	"regexp"
+)
+
 type UserDefinedFunc func(a []interface{}) (interface{}, error)
 
 type Context interface {
 	Data() interface{}
 	Func(string) (UserDefinedFunc, bool)
+	// CompileRegexp compiles patterns that are only known when an expression is evaluated.
+	CompileRegexp(string) (*regexp.Regexp, error)
+	// CoerceNumericStrings reports whether strings holding numbers are converted when compared with numbers.
+	CoerceNumericStrings() bool
 }
 
 // Value represents data types supported by the predicate expression.
@@ -24,12 +34,24 @@ type Value struct {
 	// Variables are represented by a leading $ with subelements delimited by dots $foo.bar that are associated
 	// with map keys in passed in contexts that are used to pass in data.
 	Variable *Variable `| @Variable`
-	RegularExpression *RegularExpression `| @RegularExpression`
+	// Regular expressions are surrounded by forward slashes /^foo|bar$/i
+	RegularExpression *RegularExpression `| @@`
+	// Arrays and objects written inline using JSON like syntax [1, 2] or {"key": "value"}.
+	ArrayLiteral  *ArrayLiteral  `| @@`
+	ObjectLiteral *ObjectLiteral `| @@`
+	// Case expressions of the form case when $a then 1 when $b then 2 else 3 end
+	Case *Case `| @@`
+	// Let expressions bind values to names, let $n = @len($) in $n > 0
+	Let *Let `| @@`
 	// Function
 	Function *Function `| @@`
 	// These are not set directly in expressions and are used to represent data passed by context.
 	Object map[string]interface{}
 	Array  []interface{}
+	// Regexp holds the compiled form of a regular expression literal whose pattern is held by String. It is used by the
+	// =~ and !~ operators and passed to the builtins that take a regular expression, everywhere else the literal is the
+	// pattern string.
+	Regexp *regexp.Regexp
 }
 
 func (v Value) IsNil() bool {
@@ -48,6 +70,9 @@ func (v Value) IsNil() bool {
 	if v.Array != nil {
 		return false
 	}
+	if v.Regexp != nil {
+		return false
+	}
 	return true
 }
 
@@ -65,6 +90,18 @@ func (v *Value) Eval(ctx Context) (*Value, error) {
 	if v.Function != nil {
 		return v.Function.Eval(ctx)
 	}
+	if v.ArrayLiteral != nil {
+		return v.ArrayLiteral.Eval(ctx)
+	}
+	if v.ObjectLiteral != nil {
+		return v.ObjectLiteral.Eval(ctx)
+	}
+	if v.Case != nil {
+		return v.Case.Eval(ctx)
+	}
+	if v.Let != nil {
+		return v.Let.Eval(ctx)
+	}
 	return v, nil
 }
 
@@ -72,6 +109,9 @@ func (v *Value) Eval(ctx Context) (*Value, error) {
 type UnaryOpValue struct {
 	Operator *Operator `@("!")?`
 	Value    *Value    `@@`
+	// Accessors select part of the value, @first(@select($pods, "$ready")).name or [1, 2, 3][0]. They bind more
+	// tightly than the ! operator.
+	Accessors []*Accessor `@@*`
 }
 
 func (un *UnaryOpValue) Eval(ctx Context) (*Value, error) {
@@ -79,6 +119,11 @@ func (un *UnaryOpValue) Eval(ctx Context) (*Value, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(un.Accessors) > 0 {
+		if v, err = access(v, un.Accessors); err != nil {
+			return nil, err
+		}
+	}
 	if un.Operator != nil {
 		return un.Operator.Eval(ctx, v)
 	}
@@ -87,7 +132,7 @@ func (un *UnaryOpValue) Eval(ctx Context) (*Value, error) {
 
 //nolint
 type ComparisonOpValue struct {
-	Operator Operator      `@("<" | "<=" | "==" | "!=" | ">" | ">=" )?`
+	Operator Operator      `@("<" | "<=" | "==" | "!=" | ">" | ">=" | "=~" | "!~" | "in" | "not" "in" )`
 	Value    *UnaryOpValue `@@`
 }
 
@@ -97,8 +142,12 @@ func (c *ComparisonOpValue) Eval(ctx Context) (*Value, error) {
 
 //nolint
 type ComparisonOpTerm struct {
-	Left  *UnaryOpValue        `@@`
-	Right []*ComparisonOpValue `@@*`
+	// Pos and EndPos are set by the parser to the position of the first token of the term and of the token that
+	// follows it.
+	Pos    lexer.Position
+	EndPos lexer.Position
+	Left   *UnaryOpValue        `@@`
+	Right  []*ComparisonOpValue `@@*`
 }
 
 func (c *ComparisonOpTerm) Eval(ctx Context) (*Value, error) {
@@ -121,6 +170,9 @@ func (c *ComparisonOpTerm) Eval(ctx Context) (*Value, error) {
 
 //nolint
 type LogicalOpValue struct {
+	// Pos and EndPos are set by the parser to the position of the operator and of the token that follows the value.
+	Pos      lexer.Position
+	EndPos   lexer.Position
 	Operator Operator          `@("&&" | "||")`
 	Value    *ComparisonOpTerm `@@`
 }
@@ -129,6 +181,9 @@ type LogicalOpValue struct {
 type Expression struct {
 	Left  *ComparisonOpTerm `@@`
 	Right []*LogicalOpValue `@@*`
+	// Conditional expressions of the form cond ? a : b, only the selected branch is evaluated.
+	Then *Expression `( "?" @@`
+	Else *Expression `":" @@ )?`
 }
 
 func (t *Expression) Eval(ctx Context) (*Value, error) {
@@ -146,5 +201,14 @@ func (t *Expression) Eval(ctx Context) (*Value, error) {
 			return nil, err
 		}
 	}
-	return lv, nil
+	if t.Then == nil {
+		return lv, nil
+	}
+	if lv.Bool == nil {
+		return nil, errors.New(errors.TypeMismatch, "expected boolean condition for conditional expression")
+	}
+	if bool(*lv.Bool) {
+		return t.Then.Eval(ctx)
+	}
+	return t.Else.Eval(ctx)
 }
@@ -0,0 +1,61 @@
+package ast
+
+import "github.com/murphybytes/analyze/errors"
+
+// Case evaluates to the result of the first branch that matches. Without a subject, case when $a then 1 when $b
+// then 2 else 3 end, a branch matches if its condition is true. With a subject, case $kind when "Pod" then 1 else 2
+// end, a branch matches if its value is equal to the subject. If no branch matches the result of the else branch is
+// returned or nil if there isn't one. Branches are evaluated in order and evaluation stops at the first match.
+//nolint
+type Case struct {
+	Subject  *Expression   `"case" @@?`
+	Branches []*CaseBranch `@@+`
+	Else     *Expression   `( "else" @@ )? "end"`
+}
+
+//nolint
+type CaseBranch struct {
+	When *Expression `"when" @@`
+	Then *Expression `"then" @@`
+}
+
+func (c *Case) Eval(ctx Context) (*Value, error) {
+	var subject interface{}
+	if c.Subject != nil {
+		var err error
+		if subject, err = evalToInterface(ctx, c.Subject); err != nil {
+			return nil, err
+		}
+	}
+	for _, branch := range c.Branches {
+		matched, err := branch.matches(ctx, c.Subject != nil, subject)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			return branch.Then.Eval(ctx)
+		}
+	}
+	if c.Else != nil {
+		return c.Else.Eval(ctx)
+	}
+	return &Value{NilSet: true}, nil
+}
+
+func (b *CaseBranch) matches(ctx Context, hasSubject bool, subject interface{}) (bool, error) {
+	if hasSubject {
+		when, err := evalToInterface(ctx, b.When)
+		if err != nil {
+			return false, err
+		}
+		return Equal(subject, when), nil
+	}
+	when, err := b.When.Eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	if when.Bool == nil {
+		return false, errors.New(errors.TypeMismatch, "expected boolean condition for case branch")
+	}
+	return bool(*when.Bool), nil
+}
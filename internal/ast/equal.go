@@ -0,0 +1,83 @@
+package ast
+
+// Equal reports whether two values as passed to and returned from functions are deeply equal. Numbers compare by
+// value regardless of their Go representation so an int 3 from context data equals the float 3 from an expression
+// literal. Arrays are equal if they have the same length and their elements are equal in order, objects are equal if
+// they have the same keys and the values for each key are equal. Values of different kinds are never equal.
+func Equal(l, r interface{}) bool {
+	l, r = Normalize(l), Normalize(r)
+	switch lt := l.(type) {
+	case float64:
+		rt, ok := r.(float64)
+		return ok && lt == rt
+	case string:
+		rt, ok := r.(string)
+		return ok && lt == rt
+	case bool:
+		rt, ok := r.(bool)
+		return ok && lt == rt
+	case nil:
+		return r == nil
+	case []interface{}:
+		rt, ok := r.([]interface{})
+		if !ok || len(lt) != len(rt) {
+			return false
+		}
+		for i := range lt {
+			if !Equal(lt[i], rt[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		rt, ok := r.(map[string]interface{})
+		if !ok || len(lt) != len(rt) {
+			return false
+		}
+		for k, lv := range lt {
+			rv, ok := rt[k]
+			if !ok || !Equal(lv, rv) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Normalize dereferences pointers and converts the various scalar representations supported in context data to
+// float64, string and bool. Arrays and objects are returned as they are.
+func Normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int:
+		return float64(t)
+	case *int:
+		if t == nil {
+			return nil
+		}
+		return float64(*t)
+	case *float64:
+		if t == nil {
+			return nil
+		}
+		return *t
+	case *string:
+		if t == nil {
+			return nil
+		}
+		return *t
+	case Boolean:
+		return bool(t)
+	case *Boolean:
+		if t == nil {
+			return nil
+		}
+		return bool(*t)
+	case *bool:
+		if t == nil {
+			return nil
+		}
+		return *t
+	}
+	return v
+}
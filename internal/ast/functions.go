@@ -21,7 +21,7 @@ func(f *Function) Eval(ctx Context)(*Value,error){
 		if err != nil {
 			return nil, err
 		}
-		arg, err := valToInterface(v)
+		arg, err := argToInterface(f.Name, v)
 		if err != nil {
 			fmt.Errorf("%q call is invalid %w", f.Name, err )
 		}
@@ -34,12 +34,36 @@ func(f *Function) Eval(ctx Context)(*Value,error){
 	return convertToValue(result)
 }
 
+// Interface returns the value in the form passed to and returned by functions, numbers are float64, objects
+// map[string]interface{} and arrays []interface{}.
+func (v *Value) Interface() (interface{}, error) {
+	return valToInterface(v)
+}
+
+// regexpFunctions are the builtins that take a regular expression argument. They receive regular expression literals
+// in the form compiled when the expression was parsed, every other function receives the pattern string so literals
+// behave like any other string value.
+var regexpFunctions = map[string]bool{
+	"@match":      true,
+	"@capture":    true,
+	"@find_all":   true,
+	"@replace_re": true,
+}
+
+// argToInterface converts an argument of the function called name.
+func argToInterface(name string, v *Value) (interface{}, error) {
+	if v.Regexp != nil && regexpFunctions[name] {
+		return v.Regexp, nil
+	}
+	return valToInterface(v)
+}
+
 func valToInterface(v *Value)(interface{},error){
 	switch {
 	case v.String != nil :
 		return *v.String, nil
 	case v.Bool != nil :
-		return *v.Bool, nil
+		return bool(*v.Bool), nil
 	case v.Number != nil :
 		return *v.Number, nil
 	case bool(v.NilSet):
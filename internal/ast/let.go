@@ -0,0 +1,88 @@
+package ast
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/murphybytes/analyze/errors"
+)
+
+// Let evaluates sub-expressions once and binds their results to names that can be referenced as variables in the
+// body, for example let $n = @len($) in $n > 0 && $n <= 2. Bindings are evaluated in order so a binding can refer to
+// the ones before it. Bound names shadow data in the context and names bound by enclosing let expressions, and are
+// only visible in the body of the let that binds them, including @select predicates called from the body. A bound
+// value is a single term such as a variable, function call or literal, more complex expressions must be surrounded by
+// parenthesis, let $ok = ($a && $b) in ...
+//nolint
+type Let struct {
+	Bindings []*Binding  `"let" @@ ( "," @@ )* "in"`
+	Body     *Expression `@@`
+}
+
+//nolint
+type Binding struct {
+	Name  BindingName   `@Variable "="`
+	Value *UnaryOpValue `@@`
+}
+
+func (l *Let) Eval(ctx Context) (*Value, error) {
+	for _, binding := range l.Bindings {
+		v, err := binding.Value.Eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		val, err := valToInterface(v)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := ctx.(bindingContext); ok {
+			ctx = b.Bind(string(binding.Name), val)
+			continue
+		}
+		ctx = &scope{Context: ctx, name: string(binding.Name), value: val}
+	}
+	return l.Body.Eval(ctx)
+}
+
+// BindingName is the name a let expression binds a value to, it is written like a variable without any path
+// segments, $name.
+type BindingName string
+
+var bindingNameMatcher = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+func (b *BindingName) Capture(s []string) error {
+	name := strings.TrimPrefix(strings.Join(s, ""), "$")
+	if !bindingNameMatcher.MatchString(name) {
+		return errors.NewSyntaxError("%q is not a valid name for a let binding", "$"+name)
+	}
+	*b = BindingName(name)
+	return nil
+}
+
+// binder is implemented by contexts that can resolve names bound by let expressions.
+type binder interface {
+	Lookup(name string) (interface{}, bool)
+}
+
+// bindingContext is implemented by contexts that hold let bindings themselves, so the bindings reach nested
+// expressions they evaluate such as @select predicates. Other contexts are wrapped in a scope.
+type bindingContext interface {
+	Bind(name string, value interface{}) Context
+}
+
+// scope is a context holding a single let binding, lookups for other names are passed to the enclosing context.
+type scope struct {
+	Context
+	name  string
+	value interface{}
+}
+
+func (s *scope) Lookup(name string) (interface{}, bool) {
+	if name == s.name {
+		return s.value, true
+	}
+	if b, ok := s.Context.(binder); ok {
+		return b.Lookup(name)
+	}
+	return nil, false
+}
@@ -0,0 +1,58 @@
+package ast
+
+import "github.com/murphybytes/analyze/errors"
+
+// ArrayLiteral is a JSON like array written inline in an expression, [1, "two", $three]. Elements can be any
+// expression.
+//nolint
+type ArrayLiteral struct {
+	Elements []*Expression `"[" ( @@ ( "," @@ )* )? "]"`
+}
+
+func (a *ArrayLiteral) Eval(ctx Context) (*Value, error) {
+	arr := make([]interface{}, 0, len(a.Elements))
+	for _, expr := range a.Elements {
+		elt, err := evalToInterface(ctx, expr)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, elt)
+	}
+	return &Value{Array: arr}, nil
+}
+
+// ObjectLiteral is a JSON like object written inline in an expression, {"name": "nginx", "port": 80}. Keys must be
+// strings, values can be any expression.
+//nolint
+type ObjectLiteral struct {
+	Fields []*ObjectField `"{" ( @@ ( "," @@ )* )? "}"`
+}
+
+//nolint
+type ObjectField struct {
+	Key   string      `@String ":"`
+	Value *Expression `@@`
+}
+
+func (o *ObjectLiteral) Eval(ctx Context) (*Value, error) {
+	obj := make(map[string]interface{}, len(o.Fields))
+	for _, field := range o.Fields {
+		if _, ok := obj[field.Key]; ok {
+			return nil, errors.NewSyntaxError("duplicate key %q in object literal", field.Key)
+		}
+		val, err := evalToInterface(ctx, field.Value)
+		if err != nil {
+			return nil, err
+		}
+		obj[field.Key] = val
+	}
+	return &Value{Object: obj}, nil
+}
+
+func evalToInterface(ctx Context, expr *Expression) (interface{}, error) {
+	v, err := expr.Eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return valToInterface(v)
+}
@@ -0,0 +1,289 @@
+package ast
+
+import (
+	"reflect"
+
+	"github.com/murphybytes/analyze/errors"
+)
+
+// Node is implemented by every node of an expression tree. The set of nodes is closed, Node can't be implemented
+// outside of this package.
+type Node interface {
+	// Children returns the nodes directly below the node in the order they appear in the expression. Optional parts
+	// that are missing are left out.
+	Children() []Node
+	// rewriteChildren replaces each child with the result of rewriting it.
+	rewriteChildren(r *rewriter)
+}
+
+// Rewrite traverses the tree rooted at node depth first, replacing each node with the result of calling fn once its
+// children have been rewritten, and returns the result of calling fn on node. fn must return a non nil node of the
+// same type as the one it is passed, which may be the node itself after modifying it, otherwise Rewrite stops and
+// returns an error. The tree is modified in place so it may have been partly rewritten when an error is returned.
+func Rewrite(node Node, fn func(Node) Node) (Node, error) {
+	r := &rewriter{fn: fn}
+	result := r.rewrite(node)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return result, nil
+}
+
+// rewriter holds the function nodes are rewritten with and the first error rewriting them, once there is an error
+// the remaining nodes are left as they are.
+type rewriter struct {
+	fn  func(Node) Node
+	err error
+}
+
+// rewrite returns the result of rewriting node, which always has the same type as node so callers can assert it.
+func (r *rewriter) rewrite(node Node) Node {
+	if r.err != nil {
+		return node
+	}
+	node.rewriteChildren(r)
+	if r.err != nil {
+		return node
+	}
+	result := r.fn(node)
+	if result == nil || reflect.TypeOf(result) != reflect.TypeOf(node) || reflect.ValueOf(result).IsNil() {
+		r.err = errors.New(errors.TypeMismatch, "rewriting %T returned %T, a node must be replaced by one of the same type",
+			node, result)
+		return node
+	}
+	return result
+}
+
+func (t *Expression) Children() []Node {
+	nodes := []Node{t.Left}
+	for _, r := range t.Right {
+		nodes = append(nodes, r)
+	}
+	if t.Then != nil {
+		nodes = append(nodes, t.Then, t.Else)
+	}
+	return nodes
+}
+
+func (t *Expression) rewriteChildren(r *rewriter) {
+	t.Left = r.rewrite(t.Left).(*ComparisonOpTerm)
+	for i, right := range t.Right {
+		t.Right[i] = r.rewrite(right).(*LogicalOpValue)
+	}
+	if t.Then != nil {
+		t.Then = r.rewrite(t.Then).(*Expression)
+		t.Else = r.rewrite(t.Else).(*Expression)
+	}
+}
+
+func (l *LogicalOpValue) Children() []Node {
+	return []Node{l.Value}
+}
+
+func (l *LogicalOpValue) rewriteChildren(r *rewriter) {
+	l.Value = r.rewrite(l.Value).(*ComparisonOpTerm)
+}
+
+func (c *ComparisonOpTerm) Children() []Node {
+	nodes := []Node{c.Left}
+	for _, r := range c.Right {
+		nodes = append(nodes, r)
+	}
+	return nodes
+}
+
+func (c *ComparisonOpTerm) rewriteChildren(r *rewriter) {
+	c.Left = r.rewrite(c.Left).(*UnaryOpValue)
+	for i, right := range c.Right {
+		c.Right[i] = r.rewrite(right).(*ComparisonOpValue)
+	}
+}
+
+func (c *ComparisonOpValue) Children() []Node {
+	return []Node{c.Value}
+}
+
+func (c *ComparisonOpValue) rewriteChildren(r *rewriter) {
+	c.Value = r.rewrite(c.Value).(*UnaryOpValue)
+}
+
+func (un *UnaryOpValue) Children() []Node {
+	nodes := []Node{un.Value}
+	for _, a := range un.Accessors {
+		nodes = append(nodes, a)
+	}
+	return nodes
+}
+
+func (un *UnaryOpValue) rewriteChildren(r *rewriter) {
+	un.Value = r.rewrite(un.Value).(*Value)
+	for i, a := range un.Accessors {
+		un.Accessors[i] = r.rewrite(a).(*Accessor)
+	}
+}
+
+// Children returns the node held by a value, numbers, strings, booleans and nil written in an expression are held
+// by the value itself so it has no children.
+func (v *Value) Children() []Node {
+	switch {
+	case v.Subexpression != nil:
+		return []Node{v.Subexpression}
+	case v.Variable != nil:
+		return []Node{v.Variable}
+	case v.RegularExpression != nil:
+		return []Node{v.RegularExpression}
+	case v.ArrayLiteral != nil:
+		return []Node{v.ArrayLiteral}
+	case v.ObjectLiteral != nil:
+		return []Node{v.ObjectLiteral}
+	case v.Case != nil:
+		return []Node{v.Case}
+	case v.Let != nil:
+		return []Node{v.Let}
+	case v.Function != nil:
+		return []Node{v.Function}
+	}
+	return nil
+}
+
+func (v *Value) rewriteChildren(r *rewriter) {
+	switch {
+	case v.Subexpression != nil:
+		v.Subexpression = r.rewrite(v.Subexpression).(*Expression)
+	case v.Variable != nil:
+		v.Variable = r.rewrite(v.Variable).(*Variable)
+	case v.RegularExpression != nil:
+		v.RegularExpression = r.rewrite(v.RegularExpression).(*RegularExpression)
+	case v.ArrayLiteral != nil:
+		v.ArrayLiteral = r.rewrite(v.ArrayLiteral).(*ArrayLiteral)
+	case v.ObjectLiteral != nil:
+		v.ObjectLiteral = r.rewrite(v.ObjectLiteral).(*ObjectLiteral)
+	case v.Case != nil:
+		v.Case = r.rewrite(v.Case).(*Case)
+	case v.Let != nil:
+		v.Let = r.rewrite(v.Let).(*Let)
+	case v.Function != nil:
+		v.Function = r.rewrite(v.Function).(*Function)
+	}
+}
+
+func (f *Function) Children() []Node {
+	return expressionNodes(f.Args)
+}
+
+func (f *Function) rewriteChildren(r *rewriter) {
+	rewriteExpressions(f.Args, r)
+}
+
+func (a *ArrayLiteral) Children() []Node {
+	return expressionNodes(a.Elements)
+}
+
+func (a *ArrayLiteral) rewriteChildren(r *rewriter) {
+	rewriteExpressions(a.Elements, r)
+}
+
+func (o *ObjectLiteral) Children() []Node {
+	nodes := make([]Node, 0, len(o.Fields))
+	for _, field := range o.Fields {
+		nodes = append(nodes, field)
+	}
+	return nodes
+}
+
+func (o *ObjectLiteral) rewriteChildren(r *rewriter) {
+	for i, field := range o.Fields {
+		o.Fields[i] = r.rewrite(field).(*ObjectField)
+	}
+}
+
+func (o *ObjectField) Children() []Node {
+	return []Node{o.Value}
+}
+
+func (o *ObjectField) rewriteChildren(r *rewriter) {
+	o.Value = r.rewrite(o.Value).(*Expression)
+}
+
+func (c *Case) Children() []Node {
+	var nodes []Node
+	if c.Subject != nil {
+		nodes = append(nodes, c.Subject)
+	}
+	for _, branch := range c.Branches {
+		nodes = append(nodes, branch)
+	}
+	if c.Else != nil {
+		nodes = append(nodes, c.Else)
+	}
+	return nodes
+}
+
+func (c *Case) rewriteChildren(r *rewriter) {
+	if c.Subject != nil {
+		c.Subject = r.rewrite(c.Subject).(*Expression)
+	}
+	for i, branch := range c.Branches {
+		c.Branches[i] = r.rewrite(branch).(*CaseBranch)
+	}
+	if c.Else != nil {
+		c.Else = r.rewrite(c.Else).(*Expression)
+	}
+}
+
+func (b *CaseBranch) Children() []Node {
+	return []Node{b.When, b.Then}
+}
+
+func (b *CaseBranch) rewriteChildren(r *rewriter) {
+	b.When = r.rewrite(b.When).(*Expression)
+	b.Then = r.rewrite(b.Then).(*Expression)
+}
+
+func (l *Let) Children() []Node {
+	nodes := make([]Node, 0, len(l.Bindings)+1)
+	for _, binding := range l.Bindings {
+		nodes = append(nodes, binding)
+	}
+	return append(nodes, l.Body)
+}
+
+func (l *Let) rewriteChildren(r *rewriter) {
+	for i, binding := range l.Bindings {
+		l.Bindings[i] = r.rewrite(binding).(*Binding)
+	}
+	l.Body = r.rewrite(l.Body).(*Expression)
+}
+
+func (b *Binding) Children() []Node {
+	return []Node{b.Value}
+}
+
+func (b *Binding) rewriteChildren(r *rewriter) {
+	b.Value = r.rewrite(b.Value).(*UnaryOpValue)
+}
+
+// Variables, regular expressions and accessors are leaves.
+
+func (v *Variable) Children() []Node          { return nil }
+func (v *Variable) rewriteChildren(*rewriter) {}
+
+func (r *RegularExpression) Children() []Node          { return nil }
+func (r *RegularExpression) rewriteChildren(*rewriter) {}
+
+func (a *Accessor) Children() []Node          { return nil }
+func (a *Accessor) rewriteChildren(*rewriter) {}
+
+func expressionNodes(exprs []*Expression) []Node {
+	nodes := make([]Node, 0, len(exprs))
+	for _, expr := range exprs {
+		nodes = append(nodes, expr)
+	}
+	return nodes
+}
+
+func rewriteExpressions(exprs []*Expression, r *rewriter) {
+	for i, expr := range exprs {
+		exprs[i] = r.rewrite(expr).(*Expression)
+	}
+}
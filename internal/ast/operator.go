@@ -21,6 +21,10 @@ const (
 	OpOr
 	OpEqualTo
 	OpNotEqualTo
+	OpIn
+	OpNotIn
+	OpMatch
+	OpNotMatch
 )
 
 func (o *Operator) Capture(s []string) error {
@@ -35,6 +39,10 @@ func (o *Operator) Capture(s []string) error {
 		"!=": OpNotEqualTo,
 		">": OpGreaterThan,
 		">=": OpGreaterThanOrEqualTo,
+		"in": OpIn,
+		"notin": OpNotIn,
+		"=~": OpMatch,
+		"!~": OpNotMatch,
 	}
 	var ok bool
 	if *o, ok = idMap[key]; !ok {
@@ -43,6 +51,39 @@ func (o *Operator) Capture(s []string) error {
 	return nil
 }
 
+// String returns the operator as it is written in expressions.
+func (o Operator) String() string {
+	switch o {
+	case OpUnaryNot:
+		return "!"
+	case OpLessThan:
+		return "<"
+	case OpLessThanEqual:
+		return "<="
+	case OpGreaterThan:
+		return ">"
+	case OpGreaterThanOrEqualTo:
+		return ">="
+	case OpAnd:
+		return "&&"
+	case OpOr:
+		return "||"
+	case OpEqualTo:
+		return "=="
+	case OpNotEqualTo:
+		return "!="
+	case OpIn:
+		return "in"
+	case OpNotIn:
+		return "not in"
+	case OpMatch:
+		return "=~"
+	case OpNotMatch:
+		return "!~"
+	}
+	return "unknown"
+}
+
 func (o *Operator) Eval(ctx Context, values ...*Value) (*Value, error) {
 	fnMap := map[Operator]func(Context, ...*Value) (*Value, error){
 		OpUnaryNot: func(ctx Context, values ...*Value) (*Value, error) {
@@ -89,10 +130,10 @@ func (o *Operator) Eval(ctx Context, values ...*Value) (*Value, error) {
 		OpGreaterThanOrEqualTo: func(ctx Context, values ...*Value) (*Value, error) {
 			return mapBinary(values, func(l, r *Value) (*Value, error) {
 				if !hasNilNumbers(l, r) {
-					return BoolVal(*l.Number <= *r.Number), nil
+					return BoolVal(*l.Number >= *r.Number), nil
 				}
 				if !hasNilStrings(l, r) {
-					return BoolVal(*l.String <= *r.String), nil
+					return BoolVal(*l.String >= *r.String), nil
 				}
 				return nil, errors.New(errors.SyntaxError, "type mismatch")
 			})
@@ -111,6 +152,9 @@ func (o *Operator) Eval(ctx Context, values ...*Value) (*Value, error) {
 				if l.NilSet || r.NilSet {
 					return BoolVal(l.IsNil() == r.IsNil()), nil
 				}
+				if lc, rc, ok := collections(l, r); ok {
+					return BoolVal(Equal(lc, rc)), nil
+				}
 				return nil, errors.New(errors.SyntaxError, "type mismatch")
 			})
 		},
@@ -128,9 +172,48 @@ func (o *Operator) Eval(ctx Context, values ...*Value) (*Value, error) {
 				if l.NilSet || r.NilSet {
 					return BoolVal(l.IsNil() != r.IsNil()), nil
 				}
+				if lc, rc, ok := collections(l, r); ok {
+					return BoolVal(!Equal(lc, rc)), nil
+				}
 				return nil, errors.New(errors.SyntaxError, "type mismatch")
 			})
 		},
+		OpIn: func(ctx Context, values ...*Value) (*Value, error) {
+			return mapBinary(values, func(l, r *Value) (*Value, error) {
+				found, err := isMember(l, r)
+				if err != nil {
+					return nil, err
+				}
+				return BoolVal(found), nil
+			})
+		},
+		OpNotIn: func(ctx Context, values ...*Value) (*Value, error) {
+			return mapBinary(values, func(l, r *Value) (*Value, error) {
+				found, err := isMember(l, r)
+				if err != nil {
+					return nil, err
+				}
+				return BoolVal(!found), nil
+			})
+		},
+		OpMatch: func(ctx Context, values ...*Value) (*Value, error) {
+			return mapBinary(values, func(l, r *Value) (*Value, error) {
+				matched, err := matches(ctx, l, r)
+				if err != nil {
+					return nil, err
+				}
+				return BoolVal(matched), nil
+			})
+		},
+		OpNotMatch: func(ctx Context, values ...*Value) (*Value, error) {
+			return mapBinary(values, func(l, r *Value) (*Value, error) {
+				matched, err := matches(ctx, l, r)
+				if err != nil {
+					return nil, err
+				}
+				return BoolVal(!matched), nil
+			})
+		},
 		OpAnd: func(ctx Context, values ...*Value) (*Value, error) {
 			return mapBinary(values, func(l, r *Value) (*Value, error) {
 				// short circuit eval, if lval is false, ignore rval and return false
@@ -160,12 +243,43 @@ func (o *Operator) Eval(ctx Context, values ...*Value) (*Value, error) {
 	// find the function that maps to a particular operator, evaluate the variables it uses to resolve variables,
 	// functions, and subexpressions to types, and execute
 	if fn, ok := fnMap[*o]; ok {
+		if o.isComparison() && ctx.CoerceNumericStrings() {
+			values = coerceNumericStrings(values)
+		}
 		return fn(ctx, values...)
 	}
 
 	return nil, errors.New(errors.SyntaxError, "eval called on uninitialzed operator")
 }
 
+func (o Operator) isComparison() bool {
+	switch o {
+	case OpLessThan, OpLessThanEqual, OpGreaterThan, OpGreaterThanOrEqualTo, OpEqualTo, OpNotEqualTo:
+		return true
+	}
+	return false
+}
+
+// coerceNumericStrings converts a string operand to a number when the other operand is a number and the string holds
+// a decimal number, so "1234" > 100 compares numerically. Other operands are left alone.
+func coerceNumericStrings(vals []*Value) []*Value {
+	if len(vals) != 2 {
+		return vals
+	}
+	l, r := vals[0], vals[1]
+	switch {
+	case l.Number != nil && r.String != nil:
+		if f, ok := ParseNumber(*r.String); ok {
+			r = &Value{Number: &f}
+		}
+	case l.String != nil && r.Number != nil:
+		if f, ok := ParseNumber(*l.String); ok {
+			l = &Value{Number: &f}
+		}
+	}
+	return []*Value{l, r}
+}
+
 func mapBinary(vals []*Value, fn func(l, r *Value) (*Value, error)) (*Value, error) {
 	if len(vals) != 2 {
 		return nil, errors.New(errors.SyntaxError, "expected 2 arguments got %d", len(vals))
@@ -207,3 +321,66 @@ func hasNilStrings(vals ...*Value) bool {
 	}
 	return false
 }
+
+// collections returns the arrays or objects held by l and r, ok is false unless both values are collections.
+func collections(l, r *Value) (lc, rc interface{}, ok bool) {
+	collection := func(v *Value) interface{} {
+		if v.Array != nil {
+			return v.Array
+		}
+		if v.Object != nil {
+			return v.Object
+		}
+		return nil
+	}
+	lc, rc = collection(l), collection(r)
+	return lc, rc, lc != nil && rc != nil
+}
+
+// isMember returns true if l is an element of the array r, a key of the object r or a substring of the string r.
+func isMember(l, r *Value) (bool, error) {
+	switch {
+	case r.Array != nil:
+		lv, err := valToInterface(l)
+		if err != nil {
+			return false, err
+		}
+		for _, elt := range r.Array {
+			if Equal(elt, lv) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case r.Object != nil:
+		if l.String == nil {
+			return false, errors.New(errors.TypeMismatch, "expected string key for in operator with object")
+		}
+		_, ok := r.Object[*l.String]
+		return ok, nil
+	case r.String != nil:
+		if l.String == nil {
+			return false, errors.New(errors.TypeMismatch, "expected string for in operator with string")
+		}
+		return strings.Contains(*r.String, *l.String), nil
+	}
+	return false, errors.New(errors.TypeMismatch, "in operator expects array, object or string right operand")
+}
+
+// matches returns true if the string l matches the regular expression r. r is either a regular expression literal or
+// a string pattern which is compiled by the context.
+func matches(ctx Context, l, r *Value) (bool, error) {
+	if l.String == nil {
+		return false, errors.New(errors.TypeMismatch, "match operator expects string left operand")
+	}
+	regex := r.Regexp
+	if regex == nil {
+		if r.String == nil {
+			return false, errors.New(errors.TypeMismatch, "match operator expects regular expression right operand")
+		}
+		var err error
+		if regex, err = ctx.CompileRegexp(*r.String); err != nil {
+			return false, errors.New(errors.InvalidArgument, "invalid regular expression %q: %s", *r.String, err)
+		}
+	}
+	return regex.MatchString(*l.String), nil
+}
@@ -3,6 +3,7 @@ package ast
 import (
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
+	"strings"
 	"sync"
 )
 
@@ -15,18 +16,54 @@ func Parser() *participle.Parser {
 		def := lexer.MustSimple([]lexer.Rule{
 			{"String", `"(\\"|[^"])*"`, nil},
 			{"Number", `[-+]?(\d*\.)?\d+`, nil},
-			{"whitespace", `[ \t]+`, nil},
-			{`Keyword`, `(?i)\b(nil|true|false)\b`, nil},
-			{"Operators", `!=|<=|>=|&&|==|\|\||[!()<>,]`, nil},
-			{"Variable", `\$[a-zA-Z\-"\._\[\]1-9 ]+`, nil},
+			{"whitespace", `[ \t\r\n]+`, nil},
+			// comments are elided by the parser, tokens that follow them keep their original line and column
+			{"Comment", `//[^\n]*|/\*([^*]|\*+[^*/])*\*+/`, nil},
+			// nil, true and false are matched in any case as they always have been, the other keywords only in lower case
+			// so words such as NOT and End are identifiers
+			{`Keyword`, `\b((?i:nil|true|false)|not|in|case|when|then|else|end|let)\b`, nil},
+			// field names following a dot in accessors, @parse_json($doc).logging
+			{"Ident", `[a-zA-Z_][\w\-]*`, nil},
+			{"Operators", `!=|<=|>=|&&|==|=~|!~|\|\||[!()<>,\[\]{}:?=.]`, nil},
+			{"Variable", `\$([\w\-]+|\.|\[\s*("[^"]*"|\d+)\s*\])*`, nil},
 			{"Function", `^@[a-zA-Z_]\w*`, nil },
-			{"RegularExpression", `/\^?[0-9a-zA-Z\(\)\?\:\[\]\{\}\,\.\-\*\+\\]+\$?/`, nil},
+			{"RegularExpression", `/(\\.|[^/\\\n])+/[imsU]*`, nil},
 		})
+		regularExpressionToken = def.Symbols()["RegularExpression"]
+		commentToken = def.Symbols()["Comment"]
 		_parser = participle.MustBuild(&Expression{},
 			participle.Lexer(def),
+			participle.Elide("Comment"),
 			participle.Unquote("String"),
 			participle.UseLookahead(2),
 		)
 	})
 	return _parser
 }
+
+// commentToken is the lexer token type of comments, it is set when the parser is built.
+var commentToken lexer.TokenType
+
+// Tokens returns the tokens of expression without the final EOF, unlike the parser it keeps comments.
+func Tokens(expression string) ([]lexer.Token, error) {
+	lex, err := Parser().Lexer().Lex("", strings.NewReader(expression))
+	if err != nil {
+		return nil, err
+	}
+	var tokens []lexer.Token
+	for {
+		tok, err := lex.Next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.EOF() {
+			return tokens, nil
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// IsComment reports whether tok is a comment.
+func IsComment(tok lexer.Token) bool {
+	return tok.Type == commentToken
+}
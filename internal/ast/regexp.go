@@ -1,17 +1,69 @@
 package ast
 
-import "strings"
+import (
+	"regexp"
 
-type RegularExpression string
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
 
-func(r *RegularExpression) Capture(input []string) error {
-	*r = RegularExpression(strings.Trim(strings.Join(input, ""), "/"))
+// RegularExpression is a regular expression literal of the form /pattern/flags. The pattern uses RE2 syntax, a
+// forward slash in the pattern must be escaped as \/. Flags are any of i (case insensitive), m (multi-line), s (. matches
+// newlines) and U (ungreedy). Literals are compiled when the expression is parsed so an invalid pattern is reported
+// as a parse error with its position.
+type RegularExpression struct {
+	Pattern string
+	Flags   string
+	Regexp  *regexp.Regexp
+}
+
+// regularExpressionToken is the lexer token type of regular expression literals, it is set when the parser is built.
+var regularExpressionToken lexer.TokenType
+
+var regularExpressionMatcher = regexp.MustCompile(`^/((?:\\.|[^/\\])+)/([imsU]*)$`)
+
+// Parse implements participle.Parseable.
+func (r *RegularExpression) Parse(lex *lexer.PeekingLexer) error {
+	tok, err := lex.Peek(0)
+	if err != nil {
+		return err
+	}
+	if tok.Type != regularExpressionToken {
+		return participle.NextMatch
+	}
+	if _, err := lex.Next(); err != nil {
+		return err
+	}
+	m := regularExpressionMatcher.FindStringSubmatch(tok.Value)
+	if m == nil {
+		return participle.Errorf(tok.Pos, "malformed regular expression %s", tok.Value)
+	}
+	r.Pattern, r.Flags = m[1], m[2]
+	if r.Regexp, err = regexp.Compile(r.source()); err != nil {
+		return participle.Errorf(tok.Pos, "invalid regular expression %s: %s", tok.Value, err)
+	}
 	return nil
 }
 
-func(r *RegularExpression) Eval(_ Context)(*Value, error){
-	s := string(*r)
+// source returns the pattern with flags in the form accepted by regexp.Compile.
+func (r *RegularExpression) source() string {
+	if r.Flags == "" {
+		return r.Pattern
+	}
+	return "(?" + r.Flags + ")" + r.Pattern
+}
+
+// String returns the regular expression as it is written in an expression.
+func (r *RegularExpression) String() string {
+	return "/" + r.Pattern + "/" + r.Flags
+}
+
+// Eval returns the literal as a string holding its pattern, the form it has always had, along with the compiled
+// regular expression used by =~, !~ and the regular expression builtins.
+func (r *RegularExpression) Eval(_ Context) (*Value, error) {
+	s := r.source()
 	return &Value{
 		String: &s,
+		Regexp: r.Regexp,
 	}, nil
-}
\ No newline at end of file
+}
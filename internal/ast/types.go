@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -32,3 +34,15 @@ func BoolVal(v bool) *Value {
 		Bool: &b,
 	}
 }
+
+var decimalNumber = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?$`)
+
+// ParseNumber strictly parses a decimal number such as "42", "-1.5" or "1e3". Unlike strconv.ParseFloat surrounding
+// spaces, hexadecimal numbers, underscores and special values such as "Inf" are rejected.
+func ParseNumber(s string) (float64, bool) {
+	if !decimalNumber.MatchString(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
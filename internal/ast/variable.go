@@ -17,9 +17,53 @@ func (v *Variable) Capture(s []string) error {
 }
 
 func (v *Variable) Eval(ctx Context) (*Value, error) {
-	keys := strings.Split(string(*v), ".")
+	keys := splitPath(string(*v))
+	if b, ok := ctx.(binder); ok {
+		// names bound by let expressions take precedence over context data, the rest of the variable is
+		// resolved against the bound value
+		name := keys[0]
+		if i := strings.Index(name, "["); i >= 0 {
+			name = name[:i]
+		}
+		if val, ok := b.Lookup(name); ok {
+			if keys[0] = strings.TrimPrefix(keys[0], name); keys[0] == "" {
+				keys = keys[1:]
+			}
+			if len(keys) == 0 {
+				return convertToValue(val)
+			}
+			return walkCtx(keys, val)
+		}
+	}
 	return walkCtx(keys, ctx.Data())
 }
+// Lookup resolves a path written like a variable without the leading $, for example "spec.containers[0].name",
+// against data. It is used by functions that take the path of a field as an argument.
+func Lookup(data interface{}, path string) (interface{}, error) {
+	v, err := walkCtx(splitPath(path), data)
+	if err != nil {
+		return nil, err
+	}
+	return valToInterface(v)
+}
+
+// splitPath splits a variable into the segments delimited by dots, dots inside quoted keys such as
+// $cm.data["app.json"] don't delimit segments.
+func splitPath(path string) []string {
+	var keys []string
+	quoted, start := false, 0
+	for i, c := range path {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			keys = append(keys, path[start:i])
+			start = i + 1
+		}
+	}
+	return append(keys, path[start:])
+}
+
 // Traverse variable segments left to right using each segment to look up object in context data
 // until we get to the get to the last element, then return its value.
 func walkCtx(keys []string, val interface{})(*Value, error){
@@ -39,7 +83,7 @@ func walkCtx(keys []string, val interface{})(*Value, error){
 	return convertToValue(inf)
 }
 // matches foo[ "key" ]
-var regexObjectRef = regexp.MustCompile(`^[\w\-]*\[\s*"[\w\-]+"\s*\]$`)
+var regexObjectRef = regexp.MustCompile(`^[\w\-]*\[\s*"[^"]*"\s*\]$`)
 // matches foo[2]
 var regexArrayRef =  regexp.MustCompile(`^[\w\-]*\[\s*[0-9]+\s*\]$`)
 
@@ -80,6 +124,8 @@ func convertToValue(intf interface{})(*Value,error){
 	case bool:
 		v := Boolean(t)
 		val.Bool = &v
+	case Boolean:
+		val.Bool = &t
 	case map[string]interface{}:
 		val.Object = t
 	case []interface{}:
@@ -103,18 +149,16 @@ func resolveArrayElement(arr []interface{}, reference string)(interface{}, error
 	if err != nil {
 		return nil, errors.NewSyntaxError("error resolving array index %q", err )
 	}
-	if !(index < len(arr)) {
-		return nil, errors.NewSyntaxError("index out of range")
-	}
-	return arr[index], nil
+	return elementAt(arr, index, reference)
 }
 
 func resolveObjectField(obj map[string]interface{}, reference string)(interface{},error){
 	// handle index into object object["field"]
 	if regexObjectRef.MatchString(reference) {
-		p := strings.Split(reference, "[")
+		p := strings.SplitN(reference, "[", 2)
 		key, index := p[0], p[1]
-		index = strings.Trim(index, ` ]"`)
+		index = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(index), "]"))
+		index = strings.TrimSuffix(strings.TrimPrefix(index, `"`), `"`)
 		// we  expect an object
 		var ok bool
 		if len(key) > 0 {
@@ -122,12 +166,7 @@ func resolveObjectField(obj map[string]interface{}, reference string)(interface{
 				return nil, errors.MissingKeyError(key)
 			}
 		}
-		var result interface{}
-		if result, ok = obj[index]; !ok {
-			return nil, errors.IndexOutOfRangeError(reference)
-		}
-
-		return result, nil
+		return fieldOf(obj, index, reference)
 	}
 
 	// handle index into array array[3]
@@ -144,12 +183,27 @@ func resolveObjectField(obj map[string]interface{}, reference string)(interface{
 			return nil, errors.MissingKeyError(key)
 		}
 
-		if !(index < len(arr)) {
-			return nil, errors.IndexOutOfRangeError(reference)
-		}
-
-		return arr[index], nil
+		return elementAt(arr, index, reference)
 	}
 
 	return obj[reference], nil
+}
+
+// elementAt returns the element of arr at index, reference is the path segment or accessor reported if index is out
+// of range.
+func elementAt(arr []interface{}, index int, reference string) (interface{}, error) {
+	if index < 0 || index >= len(arr) {
+		return nil, errors.IndexOutOfRangeError(reference)
+	}
+	return arr[index], nil
+}
+
+// fieldOf returns the field of obj named key, reference is the path segment or accessor reported if obj doesn't have
+// the field.
+func fieldOf(obj map[string]interface{}, key, reference string) (interface{}, error) {
+	result, ok := obj[key]
+	if !ok {
+		return nil, errors.IndexOutOfRangeError(reference)
+	}
+	return result, nil
 }
\ No newline at end of file
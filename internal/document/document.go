@@ -0,0 +1,91 @@
+// Package document decodes JSON and YAML documents into the values expressions work with, objects are
+// map[string]interface{}, arrays []interface{} and numbers float64.
+package document
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/murphybytes/analyze/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseJSON decodes a JSON document.
+func ParseJSON(b []byte) (interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ParseYAML decodes a YAML document, only the first document of a multi document stream is returned. Plain scalars
+// are resolved with YAML 1.2 rules so on, off, yes, no, y and n are strings, timestamps are kept as the string
+// they were written as. Mapping keys that aren't strings are converted to strings and integers to numbers.
+func ParseYAML(b []byte) (interface{}, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	timestampsToStrings(&node)
+	var doc interface{}
+	if err := node.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return fromYAML(doc)
+}
+
+// timestampsToStrings retags timestamp scalars as strings so they aren't decoded to time.Time.
+func timestampsToStrings(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!timestamp" {
+		node.Tag = "!!str"
+	}
+	for _, child := range node.Content {
+		timestampsToStrings(child)
+	}
+}
+
+// fromYAML converts the values produced by the YAML decoder to the types used by expressions.
+func fromYAML(v interface{}) (interface{}, error) {
+	switch t := v.(type) {
+	case nil, string, bool, float64:
+		return t, nil
+	case int:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case uint64:
+		return float64(t), nil
+	case []interface{}:
+		arr := make([]interface{}, 0, len(t))
+		for _, elt := range t {
+			val, err := fromYAML(elt)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		return arr, nil
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(t))
+		for k, elt := range t {
+			val, err := fromYAML(elt)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = val
+		}
+		return obj, nil
+	case map[interface{}]interface{}:
+		obj := make(map[string]interface{}, len(t))
+		for k, elt := range t {
+			val, err := fromYAML(elt)
+			if err != nil {
+				return nil, err
+			}
+			obj[fmt.Sprint(k)] = val
+		}
+		return obj, nil
+	}
+	return nil, errors.UnsupportedTypeError(v)
+}